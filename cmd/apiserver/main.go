@@ -18,12 +18,19 @@ package main
 
 import (
 	"github.com/kubevela/pkg/util/log"
+	"github.com/kubevela/pkg/util/singleton"
 	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apiserver/pkg/server"
+	"k8s.io/kube-openapi/pkg/common"
+	"k8s.io/kube-openapi/pkg/validation/spec"
 	"sigs.k8s.io/apiserver-runtime/pkg/builder"
 
 	apiserveroptions "github.com/kubevela/pkg/util/apiserver/options"
 
 	"github.com/kubevela-contrib/sae-apiserver-proxy/pkg/apis/sae-apiserver/v1alpha1"
+	"github.com/kubevela-contrib/sae-apiserver-proxy/pkg/apis/sae-apiserver/v1beta1"
+	"github.com/kubevela-contrib/sae-apiserver-proxy/pkg/bundle"
+	"github.com/kubevela-contrib/sae-apiserver-proxy/pkg/generated/openapi"
 )
 
 func main() {
@@ -31,10 +38,35 @@ func main() {
 		WithLocalDebugExtension().
 		ExposeLoopbackMasterClientConfig().
 		ExposeLoopbackAuthorizer().
+		// v1alpha1 is the storage version and must be registered first
 		WithResource(&v1alpha1.SAEAPIServer{}).
+		WithResource(&v1beta1.SAEAPIServer{}).
+		WithResource(&v1alpha1.SAECredential{}).
+		WithAdditionalSchemeInstallers(v1beta1.RegisterConversions).
 		WithoutEtcd().
+		WithOpenAPIDefinitions("sae-apiserver-proxy", "v0.1.0", openapi.GetOpenAPIDefinitions).
+		WithConfigFns(func(config *server.RecommendedConfig) *server.RecommendedConfig {
+			// same as server.DefaultOpenAPIV3Config, but reuses the definition namer of
+			// OpenAPI v2 which is bound to the scheme of the apiserver
+			v3 := *config.OpenAPIConfig
+			v3.Definitions = openapi.GetOpenAPIDefinitions(func(name string) spec.Ref {
+				defName, _ := v3.GetDefinitionName(name)
+				return spec.MustCreateRef("#/components/schemas/" + common.EscapeJsonPointer(defName))
+			})
+			config.OpenAPIV3Config = &v3
+			// the emulated watch and log follow through the proxy subresource must not be cut off at the request timeout
+			config.LongRunningFunc = v1alpha1.NewLongRunningRequestCheck(config.LongRunningFunc)
+			return config
+		}).
+		WithOptionsFns(func(o *builder.ServerOptions) *builder.ServerOptions {
+			runtime.Must(v1alpha1.ApplyCertificateOptions(o))
+			return o
+		}).
+		WithServerFns(singleton.InitGenericAPIServer).
 		WithServerFns(func(server *builder.GenericAPIServer) *builder.GenericAPIServer {
 			server.Handler.FullHandlerChain = v1alpha1.NewProxyRequestEscaper(server.Handler.FullHandlerChain)
+			server.AddPostStartHookOrDie(v1alpha1.ClusterGatewayMetadataReconcilerName, v1alpha1.ReconcileClusterGatewayMetadata)
+			server.AddPostStartHookOrDie(v1alpha1.CertificateRotatorName, v1alpha1.RotateCertificates)
 			return server
 		}).
 		Build()
@@ -42,5 +74,6 @@ func main() {
 	log.AddLogFlags(cmd)
 	v1alpha1.AddFlags(cmd.Flags())
 	apiserveroptions.AddServerRunFlags(cmd.Flags())
+	cmd.AddCommand(bundle.NewExportCommand(), bundle.NewImportCommand())
 	runtime.Must(cmd.Execute())
 }
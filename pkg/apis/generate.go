@@ -18,3 +18,6 @@ package apis
 
 // Generate deepcopy methodsets and CRD manifests
 //go:generate go run -tags generate sigs.k8s.io/controller-tools/cmd/controller-gen object:headerFile=../../hack/boilerplate.go.txt paths=./...
+
+// Generate OpenAPI definitions served by the apiserver
+//go:generate go run -tags generate k8s.io/kube-openapi/cmd/openapi-gen -i k8s.io/apimachinery/pkg/apis/meta/v1,k8s.io/apimachinery/pkg/runtime,k8s.io/apimachinery/pkg/version,github.com/kubevela-contrib/sae-apiserver-proxy/pkg/apis/sae-apiserver/v1alpha1,github.com/kubevela-contrib/sae-apiserver-proxy/pkg/apis/sae-apiserver/v1beta1 -p pkg/generated/openapi -O zz_generated.openapi -h ../../hack/boilerplate.go.txt -o ../.. -r ../../hack/api-rule-violations.list
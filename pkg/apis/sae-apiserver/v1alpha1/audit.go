@@ -0,0 +1,277 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/kubevela/pkg/util/k8s"
+	"github.com/kubevela/pkg/util/singleton"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	registryrest "k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+	contextutil "sigs.k8s.io/apiserver-runtime/pkg/util/context"
+)
+
+var _ resource.SubResource = &SAEAPIServerHistoryStorage{}
+var _ registryrest.Storage = &SAEAPIServerHistoryStorage{}
+var _ registryrest.Getter = &SAEAPIServerHistoryStorage{}
+
+// AnnotationSAEAPIServerHistory holds the bounded change history of the SAEAPIServer
+const AnnotationSAEAPIServerHistory = "sae.alibaba-cloud.oam.dev/history"
+
+// Deletions are recorded as events only, which expire with the event TTL of
+// the hub, as the history is kept in the annotation of the deleted object
+const (
+	ActionCreate = "Create"
+	ActionUpdate = "Update"
+	ActionDelete = "Delete"
+)
+
+// SAEAPIServerHistory is the change history of a SAEAPIServer, served as the
+// history subresource
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type SAEAPIServerHistory struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Entries are the mutations of the SAEAPIServer, from the oldest to the latest
+	// +listType=atomic
+	Entries []SAEAPIServerHistoryEntry `json:"entries,omitempty"`
+}
+
+// SAEAPIServerHistoryEntry is one mutation of the SAEAPIServer
+type SAEAPIServerHistoryEntry struct {
+	// Time of the mutation
+	Time metav1.Time `json:"time"`
+	// Action is one of Create, Update and Delete
+	Action string `json:"action"`
+	// User who made the mutation
+	User string `json:"user"`
+	// CredentialFingerprint is the keyed digest of the credential after the mutation
+	CredentialFingerprint string `json:"credentialFingerprint,omitempty"`
+	// PreviousCredentialFingerprint is the keyed digest of the credential before the mutation
+	PreviousCredentialFingerprint string `json:"previousCredentialFingerprint,omitempty"`
+}
+
+const (
+	// keyAuditKey is the key of the audit key secret holding the HMAC key
+	keyAuditKey = "key"
+	// auditKeySize is the size in bytes of the generated HMAC key
+	auditKeySize = 32
+	// fingerprintUnknown is recorded when the audit key cannot be loaded
+	fingerprintUnknown = "<unknown>"
+)
+
+// auditKeyLoader loads the per-install HMAC key of the credential fingerprints
+// once, the failures are retried on the next mutation
+// +k8s:openapi-gen=false
+type auditKeyLoader struct {
+	mu  sync.Mutex
+	key []byte
+}
+
+var auditKey = &auditKeyLoader{}
+
+func (in *auditKeyLoader) get(ctx context.Context) ([]byte, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if in.key == nil {
+		key, err := loadOrCreateAuditKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+		in.key = key
+	}
+	return in.key, nil
+}
+
+// loadOrCreateAuditKey loads the HMAC key from the audit key secret in the
+// storage namespace, which is created with a random key if missing
+func loadOrCreateAuditKey(ctx context.Context) ([]byte, error) {
+	secrets := singleton.StaticClient.Get().CoreV1().Secrets(storageNamespace)
+	secret, err := secrets.Get(ctx, auditKeySecretName, metav1.GetOptions{})
+	if err == nil {
+		if key := secret.Data[keyAuditKey]; len(key) >= auditKeySize {
+			return key, nil
+		}
+		return nil, fmt.Errorf("audit key secret %s/%s has no valid key", storageNamespace, auditKeySecretName)
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("cannot load audit key secret %s/%s: %w", storageNamespace, auditKeySecretName, err)
+	}
+	key := make([]byte, auditKeySize)
+	if _, err = rand.Read(key); err != nil {
+		return nil, err
+	}
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: auditKeySecretName, Namespace: storageNamespace},
+		Data:       map[string][]byte{keyAuditKey: key},
+	}
+	if _, err = secrets.Create(ctx, secret, metav1.CreateOptions{}); apierrors.IsAlreadyExists(err) {
+		// created by another replica
+		return loadOrCreateAuditKey(ctx)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot save audit key secret %s/%s: %w", storageNamespace, auditKeySecretName, err)
+	}
+	klog.InfoS("audit key created", "secret", storageNamespace+"/"+auditKeySecretName)
+	return key, nil
+}
+
+// fingerprint returns a short HMAC of the credential with the audit key, which
+// tells changes apart without revealing the credential. Without the key, which
+// never leaves the storage namespace, the AK/SK cannot be guessed from it.
+func (in *SAEAPIServerCredential) fingerprint(key []byte) string {
+	bs, _ := json.Marshal(in)
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(bs)
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// credentialFingerprint returns the fingerprint of the resolved credential, so
+// that the rotation of the referred secret or SAECredential changes it. The spec
+// is fingerprinted instead if the credential cannot be resolved.
+func (in *SAEAPIServer) credentialFingerprint(ctx context.Context) string {
+	key, err := auditKey.get(ctx)
+	if err != nil {
+		klog.ErrorS(err, "cannot fingerprint the credential", "SAEAPIServer", in.Name)
+		return fingerprintUnknown
+	}
+	if cred, err := in.resolveCredential(ctx); err == nil {
+		return cred.fingerprint(key)
+	}
+	return in.Spec.SAEAPIServerCredential.fingerprint(key)
+}
+
+func newHistoryEntry(ctx context.Context, action string, previous, current *SAEAPIServer) SAEAPIServerHistoryEntry {
+	entry := SAEAPIServerHistoryEntry{Time: metav1.Now(), Action: action, User: "<unknown>"}
+	if user, ok := request.UserFrom(ctx); ok {
+		entry.User = user.GetName()
+	}
+	if previous != nil {
+		// the referred credential may have been rotated since, so the fingerprint
+		// recorded by the last mutation is preferred
+		if entries := loadHistory(previous); len(entries) > 0 && entries[len(entries)-1].CredentialFingerprint != "" {
+			entry.PreviousCredentialFingerprint = entries[len(entries)-1].CredentialFingerprint
+		} else {
+			entry.PreviousCredentialFingerprint = previous.credentialFingerprint(ctx)
+		}
+	}
+	if current != nil {
+		entry.CredentialFingerprint = current.credentialFingerprint(ctx)
+	}
+	return entry
+}
+
+func (in SAEAPIServerHistoryEntry) message() string {
+	msg := fmt.Sprintf("%sd by %s", strings.ToLower(in.Action), in.User)
+	switch {
+	case in.PreviousCredentialFingerprint == "":
+		msg += fmt.Sprintf(", credential fingerprint %s", in.CredentialFingerprint)
+	case in.CredentialFingerprint == "":
+		msg += fmt.Sprintf(", credential fingerprint was %s", in.PreviousCredentialFingerprint)
+	case in.PreviousCredentialFingerprint != in.CredentialFingerprint:
+		msg += fmt.Sprintf(", credential fingerprint changed from %s to %s", in.PreviousCredentialFingerprint, in.CredentialFingerprint)
+	default:
+		msg += ", credential unchanged"
+	}
+	return msg
+}
+
+func loadHistory(obj metav1.Object) []SAEAPIServerHistoryEntry {
+	var entries []SAEAPIServerHistoryEntry
+	if raw := obj.GetAnnotations()[AnnotationSAEAPIServerHistory]; raw != "" {
+		_ = json.Unmarshal([]byte(raw), &entries)
+	}
+	return entries
+}
+
+// appendHistory writes the history of the previous object with the new entry
+// into the secret, keeping the latest --history-limit entries
+func appendHistory(secret *corev1.Secret, previous metav1.Object, entry SAEAPIServerHistoryEntry) {
+	var entries []SAEAPIServerHistoryEntry
+	if previous != nil {
+		entries = loadHistory(previous)
+	}
+	entries = append(entries, entry)
+	if len(entries) > historyLimit {
+		entries = entries[len(entries)-historyLimit:]
+	}
+	bs, _ := json.Marshal(entries)
+	_ = k8s.AddAnnotation(secret, AnnotationSAEAPIServerHistory, string(bs))
+}
+
+var eventRecorder = singleton.NewSingleton[record.EventRecorder](func() record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: singleton.StaticClient.Get().CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "sae-apiserver-proxy"})
+})
+
+// recordEvent records the mutation as an event of the SAEAPIServer
+func recordEvent(apiserver *SAEAPIServer, entry SAEAPIServerHistoryEntry) {
+	obj := apiserver.DeepCopy()
+	obj.SetGroupVersionKind(GroupVersion.WithKind("SAEAPIServer"))
+	eventRecorder.Get().Event(obj, corev1.EventTypeNormal, entry.Action+"d", entry.message())
+}
+
+// +k8s:openapi-gen=false
+type SAEAPIServerHistoryStorage struct{}
+
+func (in *SAEAPIServerHistoryStorage) SubResourceName() string {
+	return "history"
+}
+
+func (in *SAEAPIServerHistoryStorage) New() runtime.Object {
+	return &SAEAPIServerHistory{}
+}
+
+func (in *SAEAPIServerHistoryStorage) Destroy() {}
+
+func (in *SAEAPIServerHistoryStorage) Get(ctx context.Context, name string, options *metav1.GetOptions) (runtime.Object, error) {
+	parentStorage, ok := contextutil.GetParentStorageGetter(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no parent storage found")
+	}
+	parentObj, err := parentStorage.Get(ctx, name, options)
+	if err != nil {
+		return nil, err
+	}
+	apiserver := parentObj.(*SAEAPIServer)
+	history := &SAEAPIServerHistory{Entries: loadHistory(apiserver)}
+	history.SetName(apiserver.Name)
+	history.SetUID(apiserver.UID)
+	history.SetResourceVersion(apiserver.ResourceVersion)
+	history.SetCreationTimestamp(apiserver.CreationTimestamp)
+	return history, nil
+}
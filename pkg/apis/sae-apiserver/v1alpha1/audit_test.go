@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kubevela/pkg/util/singleton"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// setFakeAuditKeyClient serves the audit key secret from the objects, with the
+// loaded key reset
+func setFakeAuditKeyClient(t *testing.T, objs ...runtime.Object) *kubefake.Clientset {
+	prevKey, prevClient := auditKey, singleton.StaticClient.Get()
+	t.Cleanup(func() {
+		auditKey = prevKey
+		singleton.StaticClient.Set(prevClient)
+	})
+	auditKey = &auditKeyLoader{}
+	client := kubefake.NewSimpleClientset(objs...)
+	singleton.StaticClient.Set(client)
+	return client
+}
+
+func TestCredentialFingerprintAuditKey(t *testing.T) {
+	ctx := context.Background()
+	apiserver := &SAEAPIServer{ObjectMeta: metav1.ObjectMeta{Name: "sae"}}
+	apiserver.Spec.AccessKeyId, apiserver.Spec.AccessKeySecret = "ak", "sk"
+
+	// the key is generated on first use and kept in the storage namespace
+	client := setFakeAuditKeyClient(t)
+	fingerprint := apiserver.credentialFingerprint(ctx)
+	secret, err := client.CoreV1().Secrets(storageNamespace).Get(ctx, auditKeySecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the audit key secret to be created, got %v", err)
+	}
+	if len(secret.Data[keyAuditKey]) != auditKeySize || len(fingerprint) != 16 {
+		t.Fatalf("unexpected key of %d bytes and fingerprint %s", len(secret.Data[keyAuditKey]), fingerprint)
+	}
+	bs, _ := json.Marshal(&apiserver.Spec.SAEAPIServerCredential)
+	if sum := sha256.Sum256(bs); strings.HasPrefix(hex.EncodeToString(sum[:]), fingerprint) {
+		t.Fatalf("expected the fingerprint to be keyed")
+	}
+	client.ClearActions()
+	if again := apiserver.credentialFingerprint(ctx); again != fingerprint || len(client.Actions()) != 0 {
+		t.Fatalf("expected the loaded key to be reused, got %s and %d actions", again, len(client.Actions()))
+	}
+
+	// another replica, or the same one restarted, uses the same key
+	setFakeAuditKeyClient(t, secret)
+	if restarted := apiserver.credentialFingerprint(ctx); restarted != fingerprint {
+		t.Fatalf("expected the fingerprint %s with the same key, got %s", fingerprint, restarted)
+	}
+	// another install has another key
+	other := secret.DeepCopy()
+	other.Data = map[string][]byte{keyAuditKey: bytes.Repeat([]byte{1}, auditKeySize)}
+	setFakeAuditKeyClient(t, other)
+	if installed := apiserver.credentialFingerprint(ctx); installed == fingerprint {
+		t.Fatalf("expected the fingerprint to differ with another key")
+	}
+
+	// the invalid key is not used
+	other.Data = map[string][]byte{keyAuditKey: []byte("short")}
+	setFakeAuditKeyClient(t, other)
+	if unknown := apiserver.credentialFingerprint(ctx); unknown != fingerprintUnknown {
+		t.Fatalf("expected the fingerprint to be unknown, got %s", unknown)
+	}
+	// the failure to load the key is retried on the next mutation
+	client = setFakeAuditKeyClient(t)
+	client.PrependReactor("create", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, os.ErrPermission
+	})
+	if unknown := apiserver.credentialFingerprint(ctx); unknown != fingerprintUnknown {
+		t.Fatalf("expected the fingerprint to be unknown, got %s", unknown)
+	}
+	client.ReactionChain = client.ReactionChain[1:]
+	if fingerprint = apiserver.credentialFingerprint(ctx); fingerprint == fingerprintUnknown {
+		t.Fatalf("expected the key to be created on retry")
+	}
+}
+
+func TestHistoryFingerprintFollowsRotation(t *testing.T) {
+	setFakeAuditKeyClient(t, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: storageNamespace, Name: auditKeySecretName},
+		Data:       map[string][]byte{keyAuditKey: bytes.Repeat([]byte{1}, auditKeySize)},
+	})
+	credential := &SAECredential{ObjectMeta: metav1.ObjectMeta{Name: "shared"}}
+	credential.Spec.AccessKeyId, credential.Spec.AccessKeySecret = "ak", "sk"
+	setFakeKubeClient(convertSAECredentialToSecret(credential))
+	ctx := context.Background()
+
+	apiserver := &SAEAPIServer{ObjectMeta: metav1.ObjectMeta{Name: "sae"}}
+	apiserver.Spec.CredentialRef = &SAECredentialReference{Name: "shared"}
+	created := newHistoryEntry(ctx, ActionCreate, nil, apiserver)
+	secret := newSAEAPIServerSecret(apiserver)
+	appendHistory(secret, nil, created)
+	previous, err := convertSecretToSAEAPIServer(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unchanged := newHistoryEntry(ctx, ActionUpdate, previous, previous.DeepCopy())
+	if unchanged.CredentialFingerprint != created.CredentialFingerprint || !strings.Contains(unchanged.message(), "unchanged") {
+		t.Fatalf("expected the credential unchanged, got %s", unchanged.message())
+	}
+
+	credential.Spec.AccessKeySecret = "rotated"
+	if err = singleton.KubeClient.Get().Update(ctx, convertSAECredentialToSecret(credential)); err != nil {
+		t.Fatal(err)
+	}
+	rotated := newHistoryEntry(ctx, ActionUpdate, previous, previous.DeepCopy())
+	if rotated.PreviousCredentialFingerprint != created.CredentialFingerprint || rotated.CredentialFingerprint == created.CredentialFingerprint {
+		t.Fatalf("expected the fingerprint to change on rotation, got %s", rotated.message())
+	}
+}
@@ -0,0 +1,396 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/kubevela/pkg/util/singleton"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/server"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/apiserver-runtime/pkg/builder"
+)
+
+// CertificateRotatorName is the name of the post start hook rotating the
+// self-managed serving certificates
+const CertificateRotatorName = "sae-apiserver-certificate-rotator"
+
+const (
+	caValidity               = 10 * 365 * 24 * time.Hour
+	certificateCheckInterval = 10 * time.Minute
+	keyCACert                = "ca.crt"
+	keyCAKey                 = "ca.key"
+)
+
+var apiServiceResource = schema.GroupVersionResource{Group: "apiregistration.k8s.io", Version: "v1", Resource: "apiservices"}
+
+// apiServiceNames are the APIServices of the served versions, see charts/templates/apiservice.yaml
+var apiServiceNames = []string{Version + "." + Group, "v1beta1." + Group}
+
+// servingCertFile and servingKeyFile are served by the apiserver, which reloads them on change
+var servingCertFile, servingKeyFile string
+
+// certificates is the CA and the serving certificate persisted in the certificate secret
+// +k8s:openapi-gen=false
+type certificates struct {
+	caCert, caKey, cert, key []byte
+}
+
+// ApplyCertificateOptions bootstraps the self-managed certificates and serves
+// with them instead of the throwaway self-signed certificate. The proxy cannot
+// serve if the bootstrap fails, which is left to the caller.
+func ApplyCertificateOptions(o *builder.ServerOptions) error {
+	if !selfManagedCerts {
+		return nil
+	}
+	serving := &o.RecommendedOptions.SecureServing.ServerCert
+	if serving.CertKey.CertFile != "" || serving.CertKey.KeyFile != "" {
+		klog.InfoS("skip self-managed certificates as the serving certificate is given", "certFile", serving.CertKey.CertFile)
+		selfManagedCerts = false
+		return nil
+	}
+	servingCertFile = filepath.Join(serving.CertDirectory, certificateSecretName+".crt")
+	servingKeyFile = filepath.Join(serving.CertDirectory, certificateSecretName+".key")
+	serving.CertKey.CertFile, serving.CertKey.KeyFile = servingCertFile, servingKeyFile
+	if err := syncCertificates(context.Background()); err != nil {
+		return fmt.Errorf("cannot bootstrap self-managed certificates: %w", err)
+	}
+	return nil
+}
+
+// RotateCertificates rotates the self-managed certificates before they expire
+// and keeps the caBundle of the APIServices in sync
+func RotateCertificates(hookCtx server.PostStartHookContext) error {
+	if !selfManagedCerts {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-hookCtx.StopCh
+		cancel()
+	}()
+	go func() {
+		ticker := time.NewTicker(certificateCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := syncCertificates(ctx); err != nil {
+					klog.ErrorS(err, "failed to sync self-managed certificates")
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// syncCertificates loads the certificates from the certificate secret, rotates
+// them if necessary, and writes them to the serving certificate files, which
+// are reloaded by the apiserver on change
+func syncCertificates(ctx context.Context) error {
+	certs, err := loadOrRotateCertificates(ctx)
+	if err != nil {
+		return err
+	}
+	if err = writeFileIfChanged(servingCertFile, append(append([]byte{}, certs.cert...), certs.caCert...)); err != nil {
+		return err
+	}
+	if err = writeFileIfChanged(servingKeyFile, certs.key); err != nil {
+		return err
+	}
+	return patchAPIServiceCABundle(ctx, certs.caCert)
+}
+
+func loadOrRotateCertificates(ctx context.Context) (*certificates, error) {
+	secrets := singleton.StaticClient.Get().CoreV1().Secrets(storageNamespace)
+	secret, err := secrets.Get(ctx, certificateSecretName, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("cannot load certificate secret %s/%s: %w", storageNamespace, certificateSecretName, err)
+	}
+	found := err == nil
+	certs := &certificates{}
+	if found {
+		certs = &certificates{caCert: secret.Data[keyCACert], caKey: secret.Data[keyCAKey], cert: secret.Data[corev1.TLSCertKey], key: secret.Data[corev1.TLSPrivateKeyKey]}
+	}
+	rotated, err := certs.rotate(certificateDNSNames())
+	if err != nil || !rotated {
+		return certs, err
+	}
+	data := map[string][]byte{keyCACert: certs.caCert, keyCAKey: certs.caKey, corev1.TLSCertKey: certs.cert, corev1.TLSPrivateKeyKey: certs.key}
+	if !found {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: certificateSecretName, Namespace: storageNamespace},
+			Type:       corev1.SecretTypeTLS,
+			Data:       data,
+		}
+		if _, err = secrets.Create(ctx, secret, metav1.CreateOptions{}); apierrors.IsAlreadyExists(err) {
+			// created by another replica
+			return loadOrRotateCertificates(ctx)
+		}
+	} else {
+		secret.Data = data
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot save certificate secret %s/%s: %w", storageNamespace, certificateSecretName, err)
+	}
+	klog.InfoS("self-managed certificates rotated", "secret", storageNamespace+"/"+certificateSecretName)
+	return certs, nil
+}
+
+// rotate regenerates the CA and the serving certificate if they are missing,
+// invalid, about to expire or do not match the hosts
+func (in *certificates) rotate(hosts []string) (bool, error) {
+	ca, caKey, err := parseCertKey(in.caCert, in.caKey)
+	if err != nil || expiring(ca, caValidity) {
+		klog.InfoS("generating CA for self-managed certificates", "reason", reason(err, "expiring"))
+		if ca, caKey, err = generateCA(); err != nil {
+			return false, err
+		}
+		in.caCert, in.caKey = encodeCertKey(ca, caKey)
+		in.cert, in.key = nil, nil
+	}
+	cert, _, err := parseCertKey(in.cert, in.key)
+	switch {
+	case err != nil:
+	case cert.CheckSignatureFrom(ca) != nil:
+		err = fmt.Errorf("not signed by the CA")
+	case expiring(cert, certificateValidity):
+		err = fmt.Errorf("expiring")
+	case !sameHosts(cert.DNSNames, hosts):
+		err = fmt.Errorf("hosts changed")
+	default:
+		return false, nil
+	}
+	klog.InfoS("generating self-managed serving certificate", "reason", err.Error(), "hosts", hosts)
+	cert, key, err := generateServingCert(ca, caKey, hosts)
+	if err != nil {
+		return false, err
+	}
+	in.cert, in.key = encodeCertKey(cert, key)
+	return true, nil
+}
+
+func reason(err error, fallback string) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fallback
+}
+
+// expiring checks if less than one third of the validity is left
+func expiring(cert *x509.Certificate, validity time.Duration) bool {
+	return time.Until(cert.NotAfter) < validity/3
+}
+
+func sameHosts(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// certificateDNSNames returns the hosts of the serving certificate, which are
+// the host of --server-address, --cert-hosts and localhost
+func certificateDNSNames() []string {
+	hosts := []string{"localhost"}
+	if u, err := url.Parse(serverAddress); err == nil && u.Hostname() != "" && net.ParseIP(u.Hostname()) == nil {
+		hosts = append(hosts, u.Hostname())
+	}
+	for _, host := range certificateHosts {
+		if net.ParseIP(host) == nil {
+			hosts = append(hosts, host)
+		}
+	}
+	seen := map[string]bool{}
+	var dedup []string
+	for _, host := range hosts {
+		if !seen[host] {
+			seen[host] = true
+			dedup = append(dedup, host)
+		}
+	}
+	return dedup
+}
+
+func certificateIPs() []net.IP {
+	ips := []net.IP{net.ParseIP("127.0.0.1")}
+	if u, err := url.Parse(serverAddress); err == nil {
+		if ip := net.ParseIP(u.Hostname()); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	for _, host := range certificateHosts {
+		if ip := net.ParseIP(host); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+func parseCertKey(certPEM, keyPEM []byte) (*x509.Certificate, crypto.Signer, error) {
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return nil, nil, fmt.Errorf("not found")
+	}
+	certs, err := certutil.ParseCertsPEM(certPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := keyutil.ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported private key")
+	}
+	return certs[0], signer, nil
+}
+
+func encodeCertKey(cert *x509.Certificate, key crypto.Signer) ([]byte, []byte) {
+	certPEM, _ := certutil.EncodeCertificates(cert)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	return certPEM, pem.EncodeToMemory(&pem.Block{Type: keyutil.PrivateKeyBlockType, Bytes: der})
+}
+
+func generateCA() (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	now := time.Now()
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("sae-apiserver-proxy-ca@%d", now.Unix())},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	return signCertificate(template, nil, nil)
+}
+
+func generateServingCert(ca *x509.Certificate, caKey crypto.Signer, hosts []string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	now := time.Now()
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "sae-apiserver-proxy"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(certificateValidity),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              hosts,
+		IPAddresses:           certificateIPs(),
+	}
+	return signCertificate(template, ca, caKey)
+}
+
+// signCertificate signs the template with the parent, or self-signs it if the parent is nil
+func signCertificate(template, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	if template.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128)); err != nil {
+		return nil, nil, err
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
+
+func writeFileIfChanged(path string, data []byte) error {
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	// write to a temporary file first, so that the reloader never sees a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// patchAPIServiceCABundle sets the caBundle of the APIServices to the CA of the
+// self-managed certificates, so that the aggregator verifies the proxy
+func patchAPIServiceCABundle(ctx context.Context, caBundle []byte) error {
+	apiservices := singleton.DynamicClient.Get().Resource(apiServiceResource)
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"caBundle": caBundle, "insecureSkipTLSVerify": false},
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range apiServiceNames {
+		apiservice, err := apiservices.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cannot get APIService %s: %w", name, err)
+		}
+		if current, _, _ := unstructured.NestedString(apiservice.Object, "spec", "caBundle"); current == base64.StdEncoding.EncodeToString(caBundle) {
+			continue
+		}
+		if _, err = apiservices.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("cannot patch caBundle of APIService %s: %w", name, err)
+		}
+		klog.InfoS("caBundle of APIService updated", "APIService", name)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kubevela/pkg/util/singleton"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	genericoptions "k8s.io/apiserver/pkg/server/options"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	certutil "k8s.io/client-go/util/cert"
+	"sigs.k8s.io/apiserver-runtime/pkg/builder"
+)
+
+// newCertificateOptions are the server options serving from the temporary cert directory
+func newCertificateOptions(t *testing.T) *builder.ServerOptions {
+	o := &builder.ServerOptions{RecommendedOptions: &genericoptions.RecommendedOptions{SecureServing: genericoptions.NewSecureServingOptions().WithLoopback()}}
+	o.RecommendedOptions.SecureServing.ServerCert.CertDirectory = t.TempDir()
+	return o
+}
+
+func newAPIService(name string, caBundle []byte) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("apiregistration.k8s.io/v1")
+	obj.SetKind("APIService")
+	obj.SetName(name)
+	if caBundle != nil {
+		_ = unstructured.SetNestedField(obj.Object, base64.StdEncoding.EncodeToString(caBundle), "spec", "caBundle")
+	}
+	return obj
+}
+
+// setFakeCertificateClients sets the kube and dynamic clients used by the certificates
+func setFakeCertificateClients(t *testing.T, secrets []runtime.Object, apiservices ...runtime.Object) (*kubefake.Clientset, *dynamicfake.FakeDynamicClient) {
+	prevSelfManaged := selfManagedCerts
+	selfManagedCerts = true
+	prevClient, prevDynamicClient := singleton.StaticClient.Get(), singleton.DynamicClient.Get()
+	t.Cleanup(func() {
+		selfManagedCerts = prevSelfManaged
+		singleton.StaticClient.Set(prevClient)
+		singleton.DynamicClient.Set(prevDynamicClient)
+	})
+	client := kubefake.NewSimpleClientset(secrets...)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{apiServiceResource: "APIServiceList"}, apiservices...)
+	singleton.StaticClient.Set(client)
+	singleton.DynamicClient.Set(dynamicClient)
+	return client, dynamicClient
+}
+
+func getCertificateSecret(t *testing.T, client *kubefake.Clientset) *corev1.Secret {
+	secret, err := client.CoreV1().Secrets(storageNamespace).Get(context.Background(), certificateSecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("cannot get the certificate secret: %v", err)
+	}
+	return secret
+}
+
+// verifyServingCertificate checks the serving files are the certificate of the
+// secret signed by its CA
+func verifyServingCertificate(t *testing.T, secret *corev1.Secret) {
+	certFile, err := os.ReadFile(servingCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(certFile, append(append([]byte{}, secret.Data[corev1.TLSCertKey]...), secret.Data[keyCACert]...)) {
+		t.Fatalf("expected the serving certificate file to be the certificate and the CA of the secret")
+	}
+	if keyFile, _ := os.ReadFile(servingKeyFile); !bytes.Equal(keyFile, secret.Data[corev1.TLSPrivateKeyKey]) {
+		t.Fatalf("expected the serving key file to be the key of the secret")
+	}
+	roots, err := certutil.NewPoolFromBytes(secret.Data[keyCACert])
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := certutil.ParseCertsPEM(secret.Data[corev1.TLSCertKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = certs[0].Verify(x509.VerifyOptions{DNSName: "localhost", Roots: roots}); err != nil {
+		t.Fatalf("expected the serving certificate to be signed by the CA: %v", err)
+	}
+}
+
+func countActions(actions []k8stesting.Action, verb string) int {
+	n := 0
+	for _, action := range actions {
+		if action.GetVerb() == verb {
+			n++
+		}
+	}
+	return n
+}
+
+func TestApplyCertificateOptionsBootstrap(t *testing.T) {
+	client, dynamicClient := setFakeCertificateClients(t, nil, newAPIService(apiServiceNames[0], nil))
+	o := newCertificateOptions(t)
+	if err := ApplyCertificateOptions(o); err != nil {
+		t.Fatal(err)
+	}
+	if certKey := o.RecommendedOptions.SecureServing.ServerCert.CertKey; certKey.CertFile != servingCertFile || certKey.KeyFile != servingKeyFile {
+		t.Fatalf("expected the apiserver to serve the self-managed certificate, got %+v", certKey)
+	}
+	secret := getCertificateSecret(t, client)
+	verifyServingCertificate(t, secret)
+
+	// the caBundle of the APIService is the CA, the missing APIService is skipped
+	apiservice, err := dynamicClient.Resource(apiServiceResource).Get(context.Background(), apiServiceNames[0], metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	caBundle, _, _ := unstructured.NestedString(apiservice.Object, "spec", "caBundle")
+	if caBundle != base64.StdEncoding.EncodeToString(secret.Data[keyCACert]) {
+		t.Fatalf("expected the caBundle to be the CA, got %s", caBundle)
+	}
+	if skip, found, _ := unstructured.NestedBool(apiservice.Object, "spec", "insecureSkipTLSVerify"); !found || skip {
+		t.Fatalf("expected the TLS verification to be turned on")
+	}
+}
+
+func TestApplyCertificateOptionsReuse(t *testing.T) {
+	client, dynamicClient := setFakeCertificateClients(t, nil, newAPIService(apiServiceNames[0], nil))
+	if err := ApplyCertificateOptions(newCertificateOptions(t)); err != nil {
+		t.Fatal(err)
+	}
+	secret := getCertificateSecret(t, client)
+	client.ClearActions()
+	dynamicClient.ClearActions()
+
+	// another replica, or the same one restarted, serves the certificates of the secret
+	if err := ApplyCertificateOptions(newCertificateOptions(t)); err != nil {
+		t.Fatal(err)
+	}
+	verifyServingCertificate(t, secret)
+	if n := countActions(client.Actions(), "create") + countActions(client.Actions(), "update"); n != 0 {
+		t.Fatalf("expected the valid secret to be reused, got %d writes", n)
+	}
+	// the caBundle is already the CA
+	if n := countActions(dynamicClient.Actions(), "patch"); n != 0 {
+		t.Fatalf("expected no caBundle patch, got %d", n)
+	}
+}
+
+func TestApplyCertificateOptionsRotate(t *testing.T) {
+	ca, caKey, err := generateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the serving certificate has less than a third of the validity left
+	cert, key, err := signCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "sae-apiserver-proxy"},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(certificateValidity / 4),
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:    certificateDNSNames(),
+	}, ca, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCertPEM, caKeyPEM := encodeCertKey(ca, caKey)
+	certPEM, keyPEM := encodeCertKey(cert, key)
+	expiring := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: certificateSecretName, Namespace: storageNamespace},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{keyCACert: caCertPEM, keyCAKey: caKeyPEM, corev1.TLSCertKey: certPEM, corev1.TLSPrivateKeyKey: keyPEM},
+	}
+	// the APIService trusts another CA
+	client, dynamicClient := setFakeCertificateClients(t, []runtime.Object{expiring}, newAPIService(apiServiceNames[0], []byte("stale")))
+	if err = ApplyCertificateOptions(newCertificateOptions(t)); err != nil {
+		t.Fatal(err)
+	}
+	secret := getCertificateSecret(t, client)
+	if bytes.Equal(secret.Data[corev1.TLSCertKey], certPEM) {
+		t.Fatalf("expected the expiring certificate to be rotated")
+	}
+	if !bytes.Equal(secret.Data[keyCACert], caCertPEM) {
+		t.Fatalf("expected the valid CA to be kept")
+	}
+	verifyServingCertificate(t, secret)
+	if n := countActions(dynamicClient.Actions(), "patch"); n != 1 {
+		t.Fatalf("expected the stale caBundle to be patched, got %d patches", n)
+	}
+}
+
+func TestApplyCertificateOptionsError(t *testing.T) {
+	client, _ := setFakeCertificateClients(t, nil)
+	client.PrependReactor("create", "secrets", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, os.ErrPermission
+	})
+	if err := ApplyCertificateOptions(newCertificateOptions(t)); err == nil {
+		t.Fatalf("expected the bootstrap error to be returned")
+	}
+
+	// the given serving certificate is used instead
+	o := newCertificateOptions(t)
+	o.RecommendedOptions.SecureServing.ServerCert.CertKey.CertFile = "/etc/tls/tls.crt"
+	if err := ApplyCertificateOptions(o); err != nil || selfManagedCerts {
+		t.Fatalf("expected the self-managed certificates to be skipped, got %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	// clientExpirySkew is how long before the expiration of the STS token the
+	// cached client is renewed
+	clientExpirySkew = 5 * time.Minute
+	// clientMaxIdle is the number of the idle clients kept for each credential
+	clientMaxIdle = 16
+)
+
+var (
+	clientCacheRequests = metrics.NewCounterVec(&metrics.CounterOpts{
+		Namespace:      "sae_apiserver_proxy",
+		Subsystem:      "client_cache",
+		Name:           "requests_total",
+		Help:           "Number of the SAE clients requested from the cache, partitioned by the result of hit or miss.",
+		StabilityLevel: metrics.ALPHA,
+	}, []string{"result"})
+	clientCacheSize = metrics.NewGauge(&metrics.GaugeOpts{
+		Namespace:      "sae_apiserver_proxy",
+		Subsystem:      "client_cache",
+		Name:           "size",
+		Help:           "Number of the SAE clients in the cache.",
+		StabilityLevel: metrics.ALPHA,
+	})
+)
+
+func init() {
+	legacyregistry.MustRegister(clientCacheRequests, clientCacheSize)
+}
+
+// clientCache keeps the SAE clients keyed by SAEAPIServer and region, so that the
+// connections and the assumed roles are reused across requests. The fingerprint
+// of the resolved credential tells if the cached clients are stale, which covers
+// the rotation of the referred secrets and SAECredentials.
+//
+// The SDK client is not safe for concurrent use, as it sets the timeouts of its
+// http client and refreshes the assumed role for every request. So each request
+// checks out a client of its own, which is returned to the idle clients of the
+// entry once served. Only the connection pool of the transport is shared.
+// +k8s:openapi-gen=false
+type clientCache struct {
+	mu      sync.Mutex
+	entries map[string]*clientCacheEntry
+}
+
+// +k8s:openapi-gen=false
+type clientCacheEntry struct {
+	newClient   func(transport http.RoundTripper) (*sdk.Client, error)
+	idle        chan *sdk.Client
+	transport   *http.Transport
+	fingerprint string
+	expiration  time.Time
+}
+
+// sharedTransport hides the transport from the SDK, which otherwise overrides the
+// dialer, the TLS config and the proxy of an *http.Transport for every request
+// +k8s:openapi-gen=false
+type sharedTransport struct {
+	transport *http.Transport
+}
+
+func (in *sharedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return in.transport.RoundTrip(req)
+}
+
+var proxyClients = &clientCache{entries: map[string]*clientCacheEntry{}}
+
+func clientCacheKey(name, region string) string {
+	return name + "/" + region
+}
+
+// get checks out a client of the cached entry if the fingerprint matches and it
+// is not expired, otherwise a new entry is created and cached. The client must
+// not be shared, and is returned to the entry by the release func.
+func (in *clientCache) get(key string, fingerprint string, newClientFactory func() (func(transport http.RoundTripper) (*sdk.Client, error), time.Time, error)) (*sdk.Client, func(), error) {
+	in.mu.Lock()
+	entry, found := in.entries[key]
+	in.mu.Unlock()
+	if found && entry.fingerprint == fingerprint && (entry.expiration.IsZero() || time.Now().Before(entry.expiration)) {
+		clientCacheRequests.WithLabelValues("hit").Inc()
+		return entry.checkout()
+	}
+	clientCacheRequests.WithLabelValues("miss").Inc()
+	newClient, expiration, err := newClientFactory()
+	if err != nil {
+		return nil, nil, err
+	}
+	entry = &clientCacheEntry{
+		newClient:   newClient,
+		idle:        make(chan *sdk.Client, clientMaxIdle),
+		transport:   newClientTransport(),
+		fingerprint: fingerprint,
+		expiration:  expiration,
+	}
+	in.mu.Lock()
+	if previous, found := in.entries[key]; found {
+		previous.transport.CloseIdleConnections()
+	}
+	in.entries[key] = entry
+	clientCacheSize.Set(float64(len(in.entries)))
+	in.mu.Unlock()
+	return entry.checkout()
+}
+
+// checkout takes an idle client of the entry, or creates a new one on the shared
+// transport if all are in use
+func (in *clientCacheEntry) checkout() (*sdk.Client, func(), error) {
+	var cli *sdk.Client
+	select {
+	case cli = <-in.idle:
+	default:
+		var err error
+		if cli, err = in.newClient(&sharedTransport{transport: in.transport}); err != nil {
+			return nil, nil, err
+		}
+	}
+	return cli, func() {
+		select {
+		case in.idle <- cli:
+		default:
+		}
+	}, nil
+}
+
+// evict drops the clients of the SAEAPIServer in all regions
+func (in *clientCache) evict(name string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for key, entry := range in.entries {
+		if strings.HasPrefix(key, name+"/") {
+			entry.transport.CloseIdleConnections()
+			delete(in.entries, key)
+		}
+	}
+	clientCacheSize.Set(float64(len(in.entries)))
+}
+
+// newClientTransport creates the transport shared by the clients of an entry
+func newClientTransport() *http.Transport {
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: clientConnectTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          clientMaxIdleConns,
+		MaxIdleConnsPerHost:   clientMaxIdleConns,
+		IdleConnTimeout:       clientIdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// newSDKClient creates the client of the region on the transport
+func newSDKClient(region string, transport http.RoundTripper, credential auth.Credential) (*sdk.Client, error) {
+	config := sdk.NewConfig()
+	config.Transport = transport
+	cli, err := sdk.NewClientWithOptions(region, config, credential)
+	if err != nil {
+		return nil, err
+	}
+	cli.SetConnectTimeout(clientConnectTimeout)
+	cli.SetReadTimeout(clientReadTimeout)
+	return cli, nil
+}
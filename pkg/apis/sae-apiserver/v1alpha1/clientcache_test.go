@@ -0,0 +1,112 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+)
+
+// TestClientCacheConcurrentRequests sends requests through the cached clients
+// concurrently, which is expected to pass with -race
+func TestClientCacheConcurrentRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"RequestId":"test"}`))
+	}))
+	defer server.Close()
+	cache := &clientCache{entries: map[string]*clientCacheEntry{}}
+	cred := &SAEAPIServerCredential{AccessKeyId: "ak", AccessKeySecret: "sk"}
+	newClientFactory := func() (func(transport http.RoundTripper) (*sdk.Client, error), time.Time, error) {
+		return cred.newClientFactory(context.Background(), "cn-hangzhou")
+	}
+
+	const concurrency = 32
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cli, release, err := cache.get(clientCacheKey("sae", "cn-hangzhou"), cred.digest(), newClientFactory)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer release()
+			req := requests.NewCommonRequest()
+			req.Scheme = "http"
+			req.Domain = strings.TrimPrefix(server.URL, "http://")
+			req.Product, req.Version, req.ApiName = "sae", "2019-05-06", "Test"
+			if _, err = cli.ProcessCommonRequest(req); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if entries := len(cache.entries); entries != 1 {
+		t.Fatalf("expected the clients to share one entry, got %d", entries)
+	}
+}
+
+func TestClientCacheCheckout(t *testing.T) {
+	cache := &clientCache{entries: map[string]*clientCacheEntry{}}
+	cred := &SAEAPIServerCredential{AccessKeyId: "ak", AccessKeySecret: "sk"}
+	newClientFactory := func() (func(transport http.RoundTripper) (*sdk.Client, error), time.Time, error) {
+		return cred.newClientFactory(context.Background(), "cn-hangzhou")
+	}
+	key := clientCacheKey("sae", "cn-hangzhou")
+	first, release, err := cache.get(key, cred.digest(), newClientFactory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := cache.get(key, cred.digest(), newClientFactory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatalf("expected the client in use not to be shared")
+	}
+	release()
+	third, _, err := cache.get(key, cred.digest(), newClientFactory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third != first {
+		t.Fatalf("expected the released client to be reused")
+	}
+	rotated := &SAEAPIServerCredential{AccessKeyId: "ak", AccessKeySecret: "rotated"}
+	fourth, _, err := cache.get(key, rotated.digest(), newClientFactory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fourth == first || fourth == second {
+		t.Fatalf("expected a new client after the rotation")
+	}
+}
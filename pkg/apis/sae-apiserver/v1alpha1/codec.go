@@ -0,0 +1,166 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/endpoints/handlers/negotiation"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	mediaTypeJSON     = runtime.ContentTypeJSON
+	mediaTypeYAML     = runtime.ContentTypeYAML
+	mediaTypeProtobuf = runtime.ContentTypeProtobuf
+)
+
+// proxyCodecs are the serializers of the built-in Kubernetes types, used to
+// convert between the JSON served by SAE and the media types of the clients
+var proxyCodecs = scheme.Codecs
+
+// decodeRequestContent converts protobuf and YAML request bodies to JSON, as
+// the SAE VirtualServerProxy only takes JSON. The header is updated with the
+// content type actually sent.
+func decodeRequestContent(header http.Header, data []byte) ([]byte, error) {
+	contentType := header.Get("Content-Type")
+	if len(data) == 0 || contentType == "" {
+		return data, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid Content-Type %q: %v", contentType, err))
+	}
+	switch {
+	case mediaType == mediaTypeYAML:
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("cannot decode yaml body: %v", err))
+		}
+		header.Set("Content-Type", mediaTypeJSON)
+	case strings.HasSuffix(mediaType, "+yaml"):
+		// json is valid yaml as well, so the patch type such as apply-patch+yaml is kept
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("cannot decode yaml body: %v", err))
+		}
+	case mediaType == mediaTypeProtobuf:
+		info, _ := runtime.SerializerInfoForMediaType(proxyCodecs.SupportedMediaTypes(), mediaTypeProtobuf)
+		obj, _, err := info.Serializer.Decode(data, nil, nil)
+		if err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("cannot decode protobuf body: %v", err))
+		}
+		if data, err = encodeObject(obj, mediaTypeJSON); err != nil {
+			return nil, err
+		}
+		header.Set("Content-Type", mediaTypeJSON)
+	}
+	return data, nil
+}
+
+// encodeResponse re-encodes the JSON response of SAE to the media type
+// negotiated by the Accept header of the client. The response is left as it is
+// if it is not JSON, or converted by SAE already, such as tables. The client not
+// accepting JSON is answered with 406 if the response cannot be converted, such
+// as custom resources in protobuf.
+func encodeResponse(req *http.Request, resp *http.Response) (*http.Response, error) {
+	accept := req.Header.Get("Accept")
+	if accept == "" {
+		return resp, nil
+	}
+	if mediaType, params, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != mediaTypeJSON || params["as"] != "" {
+		return resp, nil
+	}
+	options, _, err := negotiation.NegotiateOutputMediaType(req, proxyCodecs, negotiation.DefaultEndpointRestrictions)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	if options.Accepted.MediaType == mediaTypeJSON {
+		return resp, nil
+	}
+	data, err := readResponseBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	encoded, mediaType := []byte(nil), options.Accepted.MediaType
+	switch mediaType {
+	case mediaTypeYAML:
+		encoded, err = yaml.JSONToYAML(data)
+	case mediaTypeProtobuf:
+		var obj runtime.Object
+		if obj, _, err = proxyCodecs.UniversalDeserializer().Decode(data, nil, nil); err == nil {
+			encoded, err = encodeObject(obj, mediaTypeProtobuf)
+		}
+	default:
+		err = fmt.Errorf("unsupported media type %s", mediaType)
+	}
+	if err != nil {
+		if _, ok := negotiation.NegotiateMediaTypeOptions(accept, jsonSerializers, negotiation.DefaultEndpointRestrictions); !ok {
+			return nil, negotiation.NewNotAcceptableError([]string{mediaTypeJSON})
+		}
+		// the client accepting JSON as well gets the response as it is
+		encoded, mediaType = data, mediaTypeJSON
+	}
+	resp.Header.Set("Content-Type", mediaType)
+	resp.Header.Set("Content-Length", strconv.Itoa(len(encoded)))
+	resp.ContentLength = int64(len(encoded))
+	resp.Body = io.NopCloser(bytes.NewReader(encoded))
+	return resp, nil
+}
+
+// jsonSerializers are the serializers the responses of SAE are served by when
+// they cannot be converted
+var jsonSerializers = []runtime.SerializerInfo{{MediaType: mediaTypeJSON, MediaTypeType: "application", MediaTypeSubType: "json"}}
+
+// readResponseBody reads the body to be converted, which is bounded by the max
+// response size as the body of SAE is
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	defer func() { _ = resp.Body.Close() }()
+	var reader io.Reader = resp.Body
+	if maxResponseSize > 0 {
+		reader = io.LimitReader(resp.Body, maxResponseSize+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if maxResponseSize > 0 && int64(len(data)) > maxResponseSize {
+		return nil, apierrors.NewRequestEntityTooLargeError(fmt.Sprintf(
+			"the response exceeds the max response size %d bytes", maxResponseSize))
+	}
+	return data, nil
+}
+
+func encodeObject(obj runtime.Object, mediaType string) ([]byte, error) {
+	info, ok := runtime.SerializerInfoForMediaType(proxyCodecs.SupportedMediaTypes(), mediaType)
+	if !ok {
+		return nil, fmt.Errorf("unsupported media type %s", mediaType)
+	}
+	buf := &bytes.Buffer{}
+	if err := info.Serializer.Encode(obj, buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var codecConfigMap = &corev1.ConfigMap{
+	TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+	ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"},
+	Data:       map[string]string{"key": "value"},
+}
+
+func mustEncode(t *testing.T, obj runtime.Object, mediaType string) []byte {
+	data, err := encodeObject(obj, mediaType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// decodeConfigMap decodes the JSON body into the ConfigMap for comparison
+func decodeConfigMap(t *testing.T, data []byte) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{}
+	if err := json.Unmarshal(data, cm); err != nil {
+		t.Fatalf("expected a JSON ConfigMap, got %q: %v", data, err)
+	}
+	return cm
+}
+
+func TestDecodeRequestContent(t *testing.T) {
+	cases := map[string]struct {
+		contentType string
+		body        []byte
+		// expectedType is the Content-Type sent to SAE
+		expectedType string
+		// configMap tells if the body sent is the JSON ConfigMap
+		configMap  bool
+		badRequest bool
+	}{
+		"json":        {contentType: "application/json", body: mustEncode(t, codecConfigMap, mediaTypeJSON), expectedType: "application/json", configMap: true},
+		"yaml":        {contentType: "application/yaml", body: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n  namespace: default\ndata:\n  key: value\n"), expectedType: mediaTypeJSON, configMap: true},
+		"protobuf":    {contentType: "application/vnd.kubernetes.protobuf", body: mustEncode(t, codecConfigMap, mediaTypeProtobuf), expectedType: mediaTypeJSON, configMap: true},
+		"apply patch": {contentType: "application/apply-patch+yaml", body: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n  namespace: default\ndata:\n  key: value\n"), expectedType: "application/apply-patch+yaml", configMap: true},
+		"merge patch": {contentType: "application/merge-patch+json", body: []byte(`{"data":{"key":"value"}}`), expectedType: "application/merge-patch+json"},
+		"empty":       {contentType: "application/yaml", body: []byte{}, expectedType: "application/yaml"},
+		"bad yaml":    {contentType: "application/yaml", body: []byte("a: b: c"), badRequest: true},
+		"bad proto":   {contentType: "application/vnd.kubernetes.protobuf", body: []byte("not protobuf"), badRequest: true},
+		"bad type":    {contentType: "application/;", body: []byte("{}"), badRequest: true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			header := http.Header{"Content-Type": []string{c.contentType}}
+			data, err := decodeRequestContent(header, c.body)
+			if c.badRequest {
+				if !apierrors.IsBadRequest(err) {
+					t.Fatalf("expected 400, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contentType := header.Get("Content-Type"); contentType != c.expectedType {
+				t.Fatalf("expected Content-Type %s, got %s", c.expectedType, contentType)
+			}
+			if !c.configMap {
+				if !bytes.Equal(data, c.body) {
+					t.Fatalf("expected the body as it is, got %q", data)
+				}
+				return
+			}
+			if cm := decodeConfigMap(t, data); cm.Name != "cm" || cm.Data["key"] != "value" {
+				t.Fatalf("unexpected ConfigMap %+v", cm)
+			}
+		})
+	}
+}
+
+func TestEncodeResponse(t *testing.T) {
+	configMap := mustEncode(t, codecConfigMap, mediaTypeJSON)
+	custom := []byte(`{"apiVersion":"example.com/v1","kind":"Widget","metadata":{"name":"w"}}`)
+	cases := map[string]struct {
+		accept      string
+		contentType string
+		body        []byte
+		// expectedType is the Content-Type served to the client, empty for an error
+		expectedType string
+		code         int
+	}{
+		"no accept":         {contentType: "application/json", body: configMap, expectedType: "application/json"},
+		"json":              {accept: "application/json", contentType: "application/json", body: configMap, expectedType: "application/json"},
+		"any":               {accept: "*/*", contentType: "application/json", body: configMap, expectedType: "application/json"},
+		"yaml":              {accept: "application/yaml", contentType: "application/json", body: configMap, expectedType: mediaTypeYAML},
+		"protobuf":          {accept: "application/vnd.kubernetes.protobuf", contentType: "application/json", body: configMap, expectedType: mediaTypeProtobuf},
+		"protobuf fallback": {accept: "application/vnd.kubernetes.protobuf, application/json", contentType: "application/json", body: custom, expectedType: mediaTypeJSON},
+		"protobuf only":     {accept: "application/vnd.kubernetes.protobuf", contentType: "application/json", body: custom, code: http.StatusNotAcceptable},
+		"unsupported":       {accept: "text/html", contentType: "application/json", body: configMap, code: http.StatusNotAcceptable},
+		"not json":          {accept: "application/yaml", contentType: "text/plain", body: []byte("log line"), expectedType: "text/plain"},
+		"table":             {accept: "application/json;as=Table;v=v1;g=meta.k8s.io", contentType: "application/json;as=Table;v=v1;g=meta.k8s.io", body: []byte(`{"kind":"Table"}`), expectedType: "application/json;as=Table;v=v1;g=meta.k8s.io"},
+		"too large":         {accept: "application/yaml", contentType: "application/json", body: []byte(`{"data":"` + strings.Repeat("a", 2<<10) + `"}`), code: http.StatusRequestEntityTooLarge},
+	}
+	defer func(size int64) { maxResponseSize = size }(maxResponseSize)
+	maxResponseSize = 1 << 10
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/configmaps/cm", nil)
+			if c.accept != "" {
+				req.Header.Set("Accept", c.accept)
+			}
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{c.contentType}},
+				Body:       io.NopCloser(bytes.NewReader(c.body)),
+			}
+			resp, err := encodeResponse(req, resp)
+			if c.code != 0 {
+				if status, ok := err.(apierrors.APIStatus); !ok || int(status.Status().Code) != c.code {
+					t.Fatalf("expected %d, got %v", c.code, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contentType := resp.Header.Get("Content-Type"); contentType != c.expectedType {
+				t.Fatalf("expected Content-Type %s, got %s", c.expectedType, contentType)
+			}
+			data, _ := io.ReadAll(resp.Body)
+			switch c.expectedType {
+			case mediaTypeYAML, mediaTypeProtobuf:
+				if resp.ContentLength != int64(len(data)) {
+					t.Fatalf("expected Content-Length %d, got %d", len(data), resp.ContentLength)
+				}
+				obj, _, err := proxyCodecs.UniversalDeserializer().Decode(data, nil, nil)
+				if err != nil {
+					t.Fatalf("cannot decode the %s response: %v", c.expectedType, err)
+				}
+				if cm, ok := obj.(*corev1.ConfigMap); !ok || cm.Name != "cm" || cm.Data["key"] != "value" {
+					t.Fatalf("unexpected object %+v", obj)
+				}
+			default:
+				if !bytes.Equal(data, c.body) {
+					t.Fatalf("expected the response as it is, got %q", data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const encodingGzip = "gzip"
+
+// acceptsGzip tells if gzip is acceptable by the Accept-Encoding header
+func acceptsGzip(header http.Header) bool {
+	for _, value := range header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(strings.TrimSpace(coding), ";")
+			if !strings.EqualFold(strings.TrimSpace(name), encodingGzip) {
+				continue
+			}
+			if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+				if v, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64); err == nil && v == 0 {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// shouldCompress tells if the body of the size is large enough to be compressed,
+// the size is negative if unknown
+func shouldCompress(size int64) bool {
+	return compressionThreshold > 0 && (size < 0 || size >= compressionThreshold)
+}
+
+// gzipBytes compresses the request content sent to SAE
+func gzipBytes(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeContentEncoding decompresses the gzip response of the upstream, so that
+// it can be handled, such as re-encoded or diffed, before sent to the client.
+// The decompressed body is bounded by the max response size as well.
+func (in *proxyHandler) decodeContentEncoding(resp *http.Response) error {
+	encoding := resp.Header.Get("Content-Encoding")
+	if encoding == "" || strings.EqualFold(encoding, "identity") {
+		return nil
+	}
+	if !strings.EqualFold(encoding, encodingGzip) {
+		return fmt.Errorf("unsupported Content-Encoding %s in the response of SAEAPIServer %s", encoding, in.apiserver.Name)
+	}
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return fmt.Errorf("invalid gzip response of SAEAPIServer %s: %w", in.apiserver.Name, err)
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Body = io.NopCloser(&maxSizeReader{reader: reader, apiserver: in.apiserver.Name})
+	return nil
+}
+
+// maxSizeReader fails the read once more than the max response size is read
+// +k8s:openapi-gen=false
+type maxSizeReader struct {
+	reader    io.Reader
+	read      int64
+	apiserver string
+}
+
+func (in *maxSizeReader) Read(p []byte) (int, error) {
+	n, err := in.reader.Read(p)
+	if in.read += int64(n); maxResponseSize > 0 && in.read > maxResponseSize {
+		return n, apierrors.NewRequestEntityTooLargeError(fmt.Sprintf(
+			"the response of SAEAPIServer %s exceeds the max response size %d bytes", in.apiserver, maxResponseSize))
+	}
+	return n, err
+}
+
+// gzipResponseWriter compresses the response written to the client
+// +k8s:openapi-gen=false
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	writer *gzip.Writer
+}
+
+func newGzipResponseWriter(w http.ResponseWriter) *gzipResponseWriter {
+	w.Header().Set("Content-Encoding", encodingGzip)
+	w.Header().Del("Content-Length")
+	w.Header().Add("Vary", "Accept-Encoding")
+	return &gzipResponseWriter{ResponseWriter: w, writer: gzip.NewWriter(w)}
+}
+
+func (in *gzipResponseWriter) Write(p []byte) (int, error) {
+	return in.writer.Write(p)
+}
+
+func (in *gzipResponseWriter) Flush() {
+	_ = in.writer.Flush()
+	if flusher, ok := in.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (in *gzipResponseWriter) Close() error {
+	return in.writer.Close()
+}
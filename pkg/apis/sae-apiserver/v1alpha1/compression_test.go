@@ -0,0 +1,177 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	cases := map[string]struct {
+		values   []string
+		expected bool
+	}{
+		"none":        {},
+		"gzip":        {values: []string{"gzip"}, expected: true},
+		"list":        {values: []string{"deflate, GZIP;q=0.5, br"}, expected: true},
+		"values":      {values: []string{"deflate", "gzip"}, expected: true},
+		"refused":     {values: []string{"gzip;q=0"}},
+		"refused 0.0": {values: []string{"br, gzip; q=0.0"}},
+		"identity":    {values: []string{"identity"}},
+		"prefix":      {values: []string{"x-gzip2"}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if accepts := acceptsGzip(http.Header{"Accept-Encoding": c.values}); accepts != c.expected {
+				t.Fatalf("expected %t, got %t", c.expected, accepts)
+			}
+		})
+	}
+}
+
+func TestWriteResponseGzip(t *testing.T) {
+	defer func(threshold int64) { compressionThreshold = threshold }(compressionThreshold)
+	compressionThreshold = 1 << 10
+	small := bytes.Repeat([]byte("a"), 1<<10-1)
+	large := bytes.Repeat([]byte("a"), 1<<10)
+	cases := map[string]struct {
+		acceptEncoding string
+		body           []byte
+		// size is the Content-Length of the upstream response, negative if unknown
+		size int64
+		// encoding is the Content-Encoding of the upstream response
+		encoding string
+		// disabled turns off the compression by the threshold
+		disabled   bool
+		compressed bool
+	}{
+		"at threshold":    {acceptEncoding: "gzip", body: large, size: int64(len(large)), compressed: true},
+		"unknown size":    {acceptEncoding: "gzip", body: large, size: -1, compressed: true},
+		"below threshold": {acceptEncoding: "gzip", body: small, size: int64(len(small))},
+		"not accepted":    {body: large, size: int64(len(large))},
+		"refused":         {acceptEncoding: "gzip;q=0", body: large, size: int64(len(large))},
+		"encoded":         {acceptEncoding: "gzip", body: large, size: int64(len(large)), encoding: "br"},
+		"disabled":        {acceptEncoding: "gzip", body: large, size: int64(len(large)), disabled: true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if c.disabled {
+				compressionThreshold = 0
+				defer func() { compressionThreshold = 1 << 10 }()
+			}
+			handler := &proxyHandler{apiserver: &SAEAPIServer{}}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/configmaps", nil)
+			if c.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", c.acceptEncoding)
+			}
+			header := http.Header{"Content-Type": {"application/json"}, "Vary": {"Accept"}}
+			if c.size >= 0 {
+				header.Set("Content-Length", strconv.FormatInt(c.size, 10))
+			}
+			if c.encoding != "" {
+				header.Set("Content-Encoding", c.encoding)
+			}
+			recorder := httptest.NewRecorder()
+			handler.writeResponse(recorder, req, &http.Response{
+				StatusCode:    http.StatusOK,
+				Header:        header,
+				ContentLength: c.size,
+				Body:          io.NopCloser(bytes.NewReader(c.body)),
+			})
+			resp := recorder.Result()
+			vary := sets.NewString(resp.Header.Values("Vary")...)
+			if !c.compressed {
+				if resp.Header.Get("Content-Encoding") != c.encoding || vary.Has("Accept-Encoding") {
+					t.Fatalf("expected the response not to be compressed, got %v", resp.Header)
+				}
+				if c.size >= 0 && resp.Header.Get("Content-Length") != strconv.FormatInt(c.size, 10) {
+					t.Fatalf("expected Content-Length %d, got %s", c.size, resp.Header.Get("Content-Length"))
+				}
+				if !bytes.Equal(recorder.Body.Bytes(), c.body) {
+					t.Fatalf("expected the body as it is")
+				}
+				return
+			}
+			if resp.Header.Get("Content-Encoding") != encodingGzip {
+				t.Fatalf("expected the gzip response, got %v", resp.Header)
+			}
+			// the length of the compressed body is not known in advance
+			if resp.Header.Get("Content-Length") != "" {
+				t.Fatalf("expected no Content-Length, got %s", resp.Header.Get("Content-Length"))
+			}
+			// caches tell the compressed response apart, the Vary of upstream is kept
+			if !vary.HasAll("Accept", "Accept-Encoding") {
+				t.Fatalf("expected Vary Accept and Accept-Encoding, got %v", vary.List())
+			}
+			reader, err := gzip.NewReader(recorder.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, err := io.ReadAll(reader); err != nil || !bytes.Equal(data, c.body) {
+				t.Fatalf("expected the decompressed body, got %d bytes: %v", len(data), err)
+			}
+		})
+	}
+}
+
+func TestDecodeContentEncoding(t *testing.T) {
+	defer func(size int64) { maxResponseSize = size }(maxResponseSize)
+	maxResponseSize = 1 << 10
+	handler := &proxyHandler{apiserver: &SAEAPIServer{}}
+	newResponse := func(encoding string, body []byte) *http.Response {
+		header := http.Header{"Content-Length": {strconv.Itoa(len(body))}}
+		if encoding != "" {
+			header.Set("Content-Encoding", encoding)
+		}
+		return &http.Response{Header: header, ContentLength: int64(len(body)), Body: io.NopCloser(bytes.NewReader(body))}
+	}
+	small, _ := gzipBytes([]byte("hello"))
+	resp := newResponse(encodingGzip, small)
+	if err := handler.decodeContentEncoding(resp); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(resp.Body); string(data) != "hello" || resp.ContentLength != -1 || len(resp.Header) != 0 {
+		t.Fatalf("expected the decompressed body without the encoding headers, got %q %v", data, resp.Header)
+	}
+	if resp = newResponse("identity", []byte("hello")); handler.decodeContentEncoding(resp) != nil || resp.ContentLength != 5 {
+		t.Fatalf("expected the identity response as it is")
+	}
+	if err := handler.decodeContentEncoding(newResponse("br", []byte("hello"))); err == nil {
+		t.Fatalf("expected the unsupported encoding to be rejected")
+	}
+	if err := handler.decodeContentEncoding(newResponse(encodingGzip, []byte("hello"))); err == nil {
+		t.Fatalf("expected the invalid gzip to be rejected")
+	}
+	// the decompressed body is bounded as well
+	bomb, _ := gzipBytes(bytes.Repeat([]byte("a"), 2<<10))
+	resp = newResponse(encodingGzip, bomb)
+	if err := handler.decodeContentEncoding(resp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(resp.Body); !apierrors.IsRequestEntityTooLargeError(err) {
+		t.Fatalf("expected 413, got %v", err)
+	}
+}
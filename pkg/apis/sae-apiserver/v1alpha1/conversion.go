@@ -17,69 +17,212 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path"
+	"strings"
 
 	"github.com/kubevela/pkg/util/k8s"
 	"github.com/kubevela/pkg/util/singleton"
 	"github.com/oam-dev/cluster-gateway/pkg/apis/cluster/v1alpha1"
 	"github.com/oam-dev/cluster-gateway/pkg/common"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	certutil "k8s.io/client-go/util/cert"
 )
 
 const (
 	IdentAccessKeyId          = "accessKeyId"
 	IdentAccessKeySecret      = "accessKeySecret"
+	IdentSecretRef            = "secretRef"
+	IdentSTS                  = "sts"
+	IdentOIDC                 = "oidc"
+	IdentCredentialRef        = "credentialRef"
+	IdentSAEEndpoint          = "saeEndpoint"
+	IdentRegions              = "regions"
+	IdentProxy                = "proxy"
+	IdentIdentityMappings     = "identityMappings"
 	LabelSAEAPIServer         = "sae.alibaba-cloud.oam.dev/apiserver"
 	LabelKeySAEAPIServer      = "true"
 	LabelSAEAPIServerRegion   = "sae.alibaba-cloud.oam.dev/apiserver-region"
 	DefaultSAEAPIServerRegion = "cn-hangzhou"
+	// AnnotationClusterAlias is the alias of the cluster shown by `vela cluster list`
+	AnnotationClusterAlias = "cluster.core.oam.dev/cluster-alias"
 )
 
+// IsInternalMetadataKey checks if the label or annotation key is managed by the
+// proxy or cluster-gateway. Internal keys set by users are dropped on write, all
+// the others are user-defined and propagated to the cluster-gateway secret, so
+// that they can be used as cluster labels, e.g. in clusterLabelSelector.
+func IsInternalMetadataKey(key string) bool {
+	return strings.HasPrefix(key, Group+"/") ||
+		key == common.LabelKeyClusterCredentialType ||
+		key == common.LabelKeyClusterEndpointType
+}
+
+func filterUserMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+	filtered := map[string]string{}
+	for k, v := range metadata {
+		if !IsInternalMetadataKey(k) {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
 func convertSecretToSAEAPIServer(secret *corev1.Secret) (*SAEAPIServer, error) {
 	apiserver := &SAEAPIServer{}
 	apiserver.ObjectMeta = secret.ObjectMeta
-	accessKeyId, f1 := secret.Data[IdentAccessKeyId]
-	accessKeySecret, f2 := secret.Data[IdentAccessKeySecret]
-	if !f1 || !f2 {
-		return nil, fmt.Errorf("accessKey not found in secret %s/%s", storageNamespace, secret.Name)
-	}
 	if isAPIServer := k8s.GetLabel(secret, LabelSAEAPIServer); isAPIServer != LabelKeySAEAPIServer {
 		return nil, fmt.Errorf("secret %s/%s is not a SAEAPIServer secret", storageNamespace, secret.Name)
 	}
 	if apiserver.Spec.Region = k8s.GetLabel(secret, LabelSAEAPIServerRegion); apiserver.Spec.Region == "" {
 		apiserver.Spec.Region = DefaultSAEAPIServerRegion
 	}
-	apiserver.Spec.AccessKeyId = string(accessKeyId)
-	apiserver.Spec.AccessKeySecret = string(accessKeySecret)
+	apiserver.Spec.AccessKeyId = string(secret.Data[IdentAccessKeyId])
+	apiserver.Spec.AccessKeySecret = string(secret.Data[IdentAccessKeySecret])
+	if err := unmarshalSecretData(secret, IdentSecretRef, &apiserver.Spec.SecretRef); err != nil {
+		return nil, err
+	}
+	if err := unmarshalSecretData(secret, IdentSTS, &apiserver.Spec.STS); err != nil {
+		return nil, err
+	}
+	if err := unmarshalSecretData(secret, IdentOIDC, &apiserver.Spec.OIDC); err != nil {
+		return nil, err
+	}
+	if err := unmarshalSecretData(secret, IdentCredentialRef, &apiserver.Spec.CredentialRef); err != nil {
+		return nil, err
+	}
+	if apiserver.Spec.GetType() == "" {
+		return nil, fmt.Errorf("accessKey not found in secret %s/%s", storageNamespace, secret.Name)
+	}
+	apiserver.Spec.Endpoint = string(secret.Data[IdentSAEEndpoint])
+	if regions := string(secret.Data[IdentRegions]); regions != "" {
+		apiserver.Spec.Regions = strings.Split(regions, ",")
+	}
+	if err := unmarshalSecretData(secret, IdentProxy, &apiserver.Spec.Proxy); err != nil {
+		return nil, err
+	}
+	if err := unmarshalSecretData(secret, IdentIdentityMappings, &apiserver.Spec.IdentityMappings); err != nil {
+		return nil, err
+	}
+	apiserver.Status.ProxyEndpoint = string(secret.Data["endpoint"])
+	if apiserver.Status.ProxyEndpoint != "" && len(apiserver.Spec.Regions) > 0 {
+		apiserver.Status.RegionProxyEndpoints = map[string]string{}
+		for _, region := range apiserver.Spec.Regions {
+			apiserver.Status.RegionProxyEndpoints[region] = apiserver.Status.ProxyEndpoint + path.Join(proxyRegionsPrefix, region) + "/"
+		}
+	}
+	apiserver.Status.CredentialType = k8s.GetLabel(secret, common.LabelKeyClusterCredentialType)
 	return apiserver, nil
 }
 
 func convertSAEAPIServerToSecret(apiserver *SAEAPIServer) *corev1.Secret {
 	secret := &corev1.Secret{Data: map[string][]byte{}}
 	secret.ObjectMeta = apiserver.ObjectMeta
-	secret.SetNamespace(apiserver.Name)
 	secret.SetNamespace(storageNamespace)
+	secret.SetLabels(filterUserMetadata(apiserver.GetLabels()))
+	secret.SetAnnotations(filterUserMetadata(apiserver.GetAnnotations()))
 	region := apiserver.Spec.Region
 	if region == "" {
 		region = DefaultSAEAPIServerRegion
 	}
 	_ = k8s.AddLabel(secret, LabelSAEAPIServerRegion, region)
 	_ = k8s.AddLabel(secret, LabelSAEAPIServer, LabelKeySAEAPIServer)
-	secret.Data[IdentAccessKeyId] = []byte(apiserver.Spec.AccessKeyId)
-	secret.Data[IdentAccessKeySecret] = []byte(apiserver.Spec.AccessKeySecret)
+	if apiserver.Spec.hasInline() {
+		secret.Data[IdentAccessKeyId] = []byte(apiserver.Spec.AccessKeyId)
+		secret.Data[IdentAccessKeySecret] = []byte(apiserver.Spec.AccessKeySecret)
+	}
+	if apiserver.Spec.SecretRef != nil {
+		secret.Data[IdentSecretRef], _ = json.Marshal(apiserver.Spec.SecretRef)
+	}
+	if apiserver.Spec.STS != nil {
+		secret.Data[IdentSTS], _ = json.Marshal(apiserver.Spec.STS)
+	}
+	if apiserver.Spec.OIDC != nil {
+		secret.Data[IdentOIDC], _ = json.Marshal(apiserver.Spec.OIDC)
+	}
+	if apiserver.Spec.CredentialRef != nil {
+		secret.Data[IdentCredentialRef], _ = json.Marshal(apiserver.Spec.CredentialRef)
+		_ = k8s.AddLabel(secret, LabelSAECredentialName, apiserver.Spec.CredentialRef.Name)
+	}
+	if apiserver.Spec.Endpoint != "" {
+		secret.Data[IdentSAEEndpoint] = []byte(apiserver.Spec.Endpoint)
+	}
+	if len(apiserver.Spec.Regions) > 0 {
+		secret.Data[IdentRegions] = []byte(strings.Join(apiserver.Spec.Regions, ","))
+	}
+	if apiserver.Spec.Proxy != nil {
+		secret.Data[IdentProxy], _ = json.Marshal(apiserver.Spec.Proxy)
+	}
+	if len(apiserver.Spec.IdentityMappings) > 0 {
+		secret.Data[IdentIdentityMappings], _ = json.Marshal(apiserver.Spec.IdentityMappings)
+	}
 	attachClusterGatewayMetadata(secret)
 	return secret
 }
 
+func unmarshalSecretData(secret *corev1.Secret, key string, dest interface{}) error {
+	data, found := secret.Data[key]
+	if !found {
+		return nil
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("invalid %s in secret %s/%s: %w", key, storageNamespace, secret.Name, err)
+	}
+	return nil
+}
+
 func attachClusterGatewayMetadata(secret *corev1.Secret) {
 	cfg := singleton.KubeConfig.Get()
+	delete(secret.Data, "tls.crt")
+	delete(secret.Data, "tls.key")
+	delete(secret.Data, "token")
 	if cfg.TLSClientConfig.CertData != nil && cfg.TLSClientConfig.KeyData != nil {
 		secret.Data["tls.crt"] = cfg.TLSClientConfig.CertData
 		secret.Data["tls.key"] = cfg.TLSClientConfig.KeyData
 		_ = k8s.AddLabel(secret, common.LabelKeyClusterCredentialType, string(v1alpha1.CredentialTypeX509Certificate))
-	} else if len(cfg.BearerToken) > 0 {
-		secret.Data["token"] = []byte(cfg.BearerToken)
+	} else if token := loadBearerToken(cfg); len(token) > 0 {
+		secret.Data["token"] = []byte(token)
 		_ = k8s.AddLabel(secret, common.LabelKeyClusterCredentialType, string(v1alpha1.CredentialTypeServiceAccountToken))
 	}
 	secret.Data["endpoint"] = []byte(fmt.Sprintf("%s/apis/%s/%s/%s/%s/proxy/", serverAddress, Group, Version, SAEAPIServerResource, secret.Name))
+	delete(secret.Data, "ca.crt")
+	if ca := loadServingCA(); ca != nil && strings.HasPrefix(serverAddress, "https://") {
+		secret.Data["ca.crt"] = ca
+	}
+}
+
+// loadBearerToken prefers the token file to the token loaded at startup, as
+// the projected service account token is rotated by kubelet
+func loadBearerToken(cfg *rest.Config) string {
+	if cfg.BearerTokenFile != "" {
+		if token, err := os.ReadFile(cfg.BearerTokenFile); err == nil {
+			return strings.TrimSpace(string(token))
+		}
+	}
+	return cfg.BearerToken
+}
+
+// loadServingCA returns the CA of the current serving certificate of the proxy,
+// which is the last certificate in the serving certificate chain
+func loadServingCA() []byte {
+	server := singleton.GenericAPIServer.Get()
+	if server == nil || server.SecureServingInfo == nil || server.SecureServingInfo.Cert == nil {
+		return nil
+	}
+	content, _ := server.SecureServingInfo.Cert.CurrentCertKeyContent()
+	certs, err := certutil.ParseCertsPEM(content)
+	if err != nil || len(certs) == 0 {
+		return nil
+	}
+	ca, err := certutil.EncodeCertificates(certs[len(certs)-1])
+	if err != nil {
+		return nil
+	}
+	return ca
 }
@@ -0,0 +1,110 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestSecretRoundTrip(t *testing.T) {
+	proxy := &SAEAPIServerProxyConfig{
+		RequestBodyEncoding: BodyEncodingBase64,
+		CompressRequests:    true,
+		Retry: &SAEAPIServerRetryPolicy{
+			MaxRetries:     pointer.Int32(3),
+			InitialBackoff: &metav1.Duration{Duration: time.Second},
+		},
+		RateLimit:          &SAEAPIServerRateLimit{QPS: pointer.Int32(10), PerUser: pointer.Bool(true)},
+		SupportedResources: []SAEAPIServerSupportedResource{{Group: "apps", Resource: "deployments", Verbs: []string{"get"}}},
+		Headers:            &SAEAPIServerHeaderPolicy{Request: []string{"X-Trace"}},
+	}
+	cases := map[string]SAEAPIServerSpec{
+		"inline": {
+			SAEAPIServerCredential: SAEAPIServerCredential{AccessKeyId: "ak", AccessKeySecret: "sk"},
+			Region:                 "cn-beijing",
+		},
+		"secretRef": {
+			SAEAPIServerCredential: SAEAPIServerCredential{SecretRef: &SAEAPIServerSecretReference{Namespace: "ns", Name: "aksk"}},
+			Region:                 DefaultSAEAPIServerRegion,
+			Endpoint:               "sae.example.com",
+		},
+		"sts": {
+			SAEAPIServerCredential: SAEAPIServerCredential{STS: &SAEAPIServerSTSCredential{AccessKeyId: "ak", AccessKeySecret: "sk", RoleArn: "acs:ram::1:role/sae"}},
+			Region:                 DefaultSAEAPIServerRegion,
+			Regions:                []string{"cn-shanghai", "cn-shenzhen"},
+		},
+		"oidc": {
+			SAEAPIServerCredential: SAEAPIServerCredential{OIDC: &SAEAPIServerOIDCCredential{RoleArn: "acs:ram::1:role/sae", OIDCProviderArn: "acs:ram::1:oidc-provider/ack"}},
+			Region:                 DefaultSAEAPIServerRegion,
+			Proxy:                  proxy,
+		},
+		"credentialRef": {
+			SAEAPIServerCredential: SAEAPIServerCredential{CredentialRef: &SAECredentialReference{Name: "shared"}},
+			Region:                 DefaultSAEAPIServerRegion,
+			IdentityMappings: []SAEAPIServerIdentityMapping{
+				{Users: []string{"alice"}, CredentialRef: &SAECredentialReference{Name: "alice"}},
+				{Groups: []string{"dev"}, RoleArn: "acs:ram::1:role/dev", Policy: "{}"},
+			},
+		},
+	}
+	for name, spec := range cases {
+		t.Run(name, func(t *testing.T) {
+			apiserver := &SAEAPIServer{ObjectMeta: metav1.ObjectMeta{
+				Name:        "test",
+				Labels:      map[string]string{"env": "prod", LabelSAEAPIServerRegion: "dropped"},
+				Annotations: map[string]string{AnnotationClusterAlias: "prod"},
+			}, Spec: spec}
+			out, err := convertSecretToSAEAPIServer(convertSAEAPIServerToSecret(apiserver))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(out.Spec, spec) {
+				t.Fatalf("expected spec %+v, got %+v", spec, out.Spec)
+			}
+			if out.GetLabels()["env"] != "prod" || out.GetAnnotations()[AnnotationClusterAlias] != "prod" {
+				t.Fatalf("user metadata is lost: %v %v", out.GetLabels(), out.GetAnnotations())
+			}
+			if region := out.GetLabels()[LabelSAEAPIServerRegion]; region != spec.Region {
+				t.Fatalf("expected region label %s, got %s", spec.Region, region)
+			}
+			if out.Status.ProxyEndpoint == "" {
+				t.Fatal("expected the proxy endpoint in the status")
+			}
+			if len(out.Status.RegionProxyEndpoints) != len(spec.Regions) {
+				t.Fatalf("expected the proxy endpoints of %v, got %v", spec.Regions, out.Status.RegionProxyEndpoints)
+			}
+		})
+	}
+}
+
+func TestSecretDefaultRegion(t *testing.T) {
+	apiserver := &SAEAPIServer{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Spec: SAEAPIServerSpec{
+		SAEAPIServerCredential: SAEAPIServerCredential{AccessKeyId: "ak", AccessKeySecret: "sk"},
+	}}
+	out, err := convertSecretToSAEAPIServer(convertSAEAPIServerToSecret(apiserver))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Spec.Region != DefaultSAEAPIServerRegion {
+		t.Fatalf("expected region %s, got %s", DefaultSAEAPIServerRegion, out.Spec.Region)
+	}
+}
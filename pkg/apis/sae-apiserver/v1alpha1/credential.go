@@ -0,0 +1,397 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth/credentials"
+	"github.com/kubevela/pkg/util/k8s"
+	"github.com/kubevela/pkg/util/singleton"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/strings/slices"
+)
+
+// CredentialType is the type of the credential source used to access SAE
+type CredentialType string
+
+const (
+	CredentialTypeInline    CredentialType = "Inline"
+	CredentialTypeSecretRef CredentialType = "SecretRef"
+	CredentialTypeSTS       CredentialType = "STS"
+	CredentialTypeOIDC      CredentialType = "OIDC"
+	CredentialTypeRef       CredentialType = "CredentialRef"
+)
+
+const (
+	defaultRoleSessionName = "sae-apiserver-proxy"
+	defaultSTSEndpoint     = "sts.aliyuncs.com"
+	envOIDCTokenFile       = "ALIBABA_CLOUD_OIDC_TOKEN_FILE"
+)
+
+func (in *SAEAPIServerCredential) hasInline() bool {
+	return in.AccessKeyId != "" || in.AccessKeySecret != ""
+}
+
+// GetType returns the type of the credential source, empty if none is set
+func (in *SAEAPIServerCredential) GetType() CredentialType {
+	switch {
+	case in.SecretRef != nil:
+		return CredentialTypeSecretRef
+	case in.STS != nil:
+		return CredentialTypeSTS
+	case in.OIDC != nil:
+		return CredentialTypeOIDC
+	case in.CredentialRef != nil:
+		return CredentialTypeRef
+	case in.hasInline():
+		return CredentialTypeInline
+	default:
+		return ""
+	}
+}
+
+// Validate checks that exactly one credential source is set and is complete
+func (in *SAEAPIServerCredential) Validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	var sources []string
+	if in.hasInline() {
+		sources = append(sources, "accessKeyId")
+		if in.AccessKeyId == "" {
+			errs = append(errs, field.Required(fldPath.Child("accessKeyId"), ""))
+		}
+		if in.AccessKeySecret == "" {
+			errs = append(errs, field.Required(fldPath.Child("accessKeySecret"), ""))
+		}
+	}
+	if in.SecretRef != nil {
+		sources = append(sources, "secretRef")
+		if in.SecretRef.Name == "" {
+			errs = append(errs, field.Required(fldPath.Child("secretRef", "name"), ""))
+		}
+		if in.SecretRef.Namespace != "" && in.SecretRef.Namespace != storageNamespace {
+			errs = append(errs, field.Invalid(fldPath.Child("secretRef", "namespace"), in.SecretRef.Namespace,
+				fmt.Sprintf("must be the storage namespace %s", storageNamespace)))
+		}
+	}
+	if in.STS != nil {
+		sources = append(sources, "sts")
+		if in.STS.AccessKeyId == "" {
+			errs = append(errs, field.Required(fldPath.Child("sts", "accessKeyId"), ""))
+		}
+		if in.STS.AccessKeySecret == "" {
+			errs = append(errs, field.Required(fldPath.Child("sts", "accessKeySecret"), ""))
+		}
+		if in.STS.RoleArn == "" {
+			errs = append(errs, field.Required(fldPath.Child("sts", "roleArn"), ""))
+		}
+	}
+	if in.OIDC != nil {
+		sources = append(sources, "oidc")
+		if in.OIDC.RoleArn == "" {
+			errs = append(errs, field.Required(fldPath.Child("oidc", "roleArn"), ""))
+		}
+		if in.OIDC.OIDCProviderArn == "" {
+			errs = append(errs, field.Required(fldPath.Child("oidc", "oidcProviderArn"), ""))
+		}
+		errs = append(errs, ValidateEndpoint(in.OIDC.STSEndpoint, fldPath.Child("oidc", "stsEndpoint"))...)
+	}
+	if in.CredentialRef != nil {
+		sources = append(sources, "credentialRef")
+		if in.CredentialRef.Name == "" {
+			errs = append(errs, field.Required(fldPath.Child("credentialRef", "name"), ""))
+		}
+	}
+	switch len(sources) {
+	case 0:
+		errs = append(errs, field.Required(fldPath, "one of accessKeyId, secretRef, sts, oidc or credentialRef must be set"))
+	case 1:
+	default:
+		errs = append(errs, field.Invalid(fldPath, strings.Join(sources, ","), "only one credential source can be set"))
+	}
+	return errs
+}
+
+// ValidateRegions checks that the additional regions are neither empty nor duplicated
+func ValidateRegions(region string, regions []string, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if region == "" {
+		region = DefaultSAEAPIServerRegion
+	}
+	seen := map[string]bool{region: true}
+	for i, r := range regions {
+		switch {
+		case r == "":
+			errs = append(errs, field.Required(fldPath.Index(i), ""))
+		case seen[r]:
+			errs = append(errs, field.Duplicate(fldPath.Index(i), r))
+		}
+		seen[r] = true
+	}
+	return errs
+}
+
+// ValidateEndpoint checks that the endpoint override is a host, optionally with
+// a port, allowed by --allowed-endpoints
+func ValidateEndpoint(endpoint string, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if endpoint == "" {
+		return errs
+	}
+	host, ok := endpointHost(endpoint)
+	if !ok {
+		return append(errs, field.Invalid(fldPath, endpoint, "must be a host with an optional port"))
+	}
+	if !isAllowedEndpoint(host) {
+		errs = append(errs, field.Forbidden(fldPath, fmt.Sprintf("%s is not allowed by the proxy, allowed endpoints are %s",
+			host, strings.Join(allowedEndpoints, ","))))
+	}
+	return errs
+}
+
+// endpointHost returns the host of the endpoint, which must not have a scheme,
+// userinfo or path
+func endpointHost(endpoint string) (string, bool) {
+	host := endpoint
+	if h, port, err := net.SplitHostPort(endpoint); err == nil {
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			return "", false
+		}
+		host = h
+	}
+	if len(validation.IsDNS1123Subdomain(host)) > 0 {
+		return "", false
+	}
+	return host, true
+}
+
+// isAllowedEndpoint matches the host against --allowed-endpoints, where
+// *.<domain> matches the subdomains of the domain
+func isAllowedEndpoint(host string) bool {
+	for _, allowed := range allowedEndpoints {
+		if domain := strings.TrimPrefix(allowed, "*"); domain != allowed {
+			if strings.HasSuffix(host, domain) && len(host) > len(domain) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRegion checks if the region is served by the SAEAPIServer
+func (in *SAEAPIServer) HasRegion(region string) bool {
+	return region == in.Spec.Region || slices.Contains(in.Spec.Regions, region)
+}
+
+// NewClient returns the alibaba-cloud client of the region with the credential
+// mapped from the caller, or the credential of the SAEAPIServer by default.
+// Clients are cached until the resolved credential changes. The client must not
+// be shared across requests, and is returned to the cache by the release func.
+func (in *SAEAPIServer) NewClient(ctx context.Context, region string) (cli *sdk.Client, release func(), err error) {
+	cred, identity, err := in.resolveIdentityCredential(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	key := clientCacheKey(in.Name, region)
+	if identity != "" {
+		key += "/" + identity
+	}
+	return proxyClients.get(key, cred.digest(), func() (func(transport http.RoundTripper) (*sdk.Client, error), time.Time, error) {
+		return cred.newClientFactory(ctx, region)
+	})
+}
+
+// digest tells the resolved credentials apart for the client cache, which is
+// kept in memory only
+func (in *SAEAPIServerCredential) digest() string {
+	bs, _ := json.Marshal(in)
+	sum := sha256.Sum256(bs)
+	return hex.EncodeToString(sum[:])
+}
+
+// resolveCredential loads the AK/SK of the referred secret or SAECredential into
+// an inline credential, so that the rotation takes effect immediately
+func (in *SAEAPIServer) resolveCredential(ctx context.Context) (*SAEAPIServerCredential, error) {
+	cred := in.Spec.SAEAPIServerCredential.DeepCopy()
+	switch cred.GetType() {
+	case CredentialTypeSecretRef:
+		accessKeyId, accessKeySecret, err := cred.SecretRef.load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &SAEAPIServerCredential{AccessKeyId: accessKeyId, AccessKeySecret: accessKeySecret}, nil
+	case CredentialTypeRef:
+		credential, err := getSAECredential(ctx, cred.CredentialRef.Name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load SAECredential %s: %w", cred.CredentialRef.Name, err)
+		}
+		return &SAEAPIServerCredential{AccessKeyId: credential.Spec.AccessKeyId, AccessKeySecret: credential.Spec.AccessKeySecret}, nil
+	case "":
+		return nil, fmt.Errorf("no credential found in SAEAPIServer %s", in.Name)
+	default:
+		return cred, nil
+	}
+}
+
+// newClientFactory returns the func creating the clients of the resolved
+// credential, and the expiration of the assumed role if any. The OIDC role is
+// assumed once for all the clients.
+func (in *SAEAPIServerCredential) newClientFactory(ctx context.Context, region string) (func(transport http.RoundTripper) (*sdk.Client, error), time.Time, error) {
+	var credential auth.Credential
+	var expiration time.Time
+	switch in.GetType() {
+	case CredentialTypeInline:
+		credential = credentials.NewAccessKeyCredential(in.AccessKeyId, in.AccessKeySecret)
+	case CredentialTypeSTS:
+		sessionName := in.STS.RoleSessionName
+		if sessionName == "" {
+			sessionName = defaultRoleSessionName
+		}
+		credential = credentials.NewRamRoleArnWithPolicyCredential(
+			in.STS.AccessKeyId, in.STS.AccessKeySecret, in.STS.RoleArn, sessionName, in.STS.Policy, 0)
+	case CredentialTypeOIDC:
+		creds, err := in.OIDC.assumeRole(ctx)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if t, err := time.Parse(time.RFC3339, creds.Expiration); err == nil {
+			expiration = t.Add(-clientExpirySkew)
+		}
+		credential = credentials.NewStsTokenCredential(creds.AccessKeyId, creds.AccessKeySecret, creds.SecurityToken)
+	default:
+		return nil, time.Time{}, fmt.Errorf("unsupported credential type %s", in.GetType())
+	}
+	return func(transport http.RoundTripper) (*sdk.Client, error) {
+		return newSDKClient(region, transport, credential)
+	}, expiration, nil
+}
+
+func (in *SAEAPIServerSecretReference) load(ctx context.Context) (accessKeyId string, accessKeySecret string, err error) {
+	namespace, idKey, secretKey := in.Namespace, in.AccessKeyIdKey, in.AccessKeySecretKey
+	if namespace == "" {
+		namespace = storageNamespace
+	}
+	if namespace != storageNamespace {
+		return "", "", fmt.Errorf("credential secret %s/%s is not in the storage namespace %s", namespace, in.Name, storageNamespace)
+	}
+	if idKey == "" {
+		idKey = IdentAccessKeyId
+	}
+	if secretKey == "" {
+		secretKey = IdentAccessKeySecret
+	}
+	secret, err := singleton.StaticClient.Get().CoreV1().Secrets(namespace).Get(ctx, in.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("cannot load credential secret %s/%s: %w", namespace, in.Name, err)
+	}
+	// the secrets of the proxy itself hold the credentials of other objects
+	if k8s.GetLabel(secret, LabelSAEAPIServer) != "" || k8s.GetLabel(secret, LabelSAECredential) != "" || secret.Name == certificateSecretName || secret.Name == auditKeySecretName {
+		return "", "", fmt.Errorf("secret %s/%s is managed by the proxy and cannot be referred", namespace, in.Name)
+	}
+	id, f1 := secret.Data[idKey]
+	key, f2 := secret.Data[secretKey]
+	if !f1 || !f2 {
+		return "", "", fmt.Errorf("accessKey not found in secret %s/%s", namespace, in.Name)
+	}
+	return string(id), string(key), nil
+}
+
+// +k8s:openapi-gen=false
+type stsCredentials struct {
+	AccessKeyId     string `json:"AccessKeyId"`
+	AccessKeySecret string `json:"AccessKeySecret"`
+	SecurityToken   string `json:"SecurityToken"`
+	Expiration      string `json:"Expiration"`
+}
+
+// +k8s:openapi-gen=false
+type assumeRoleWithOIDCResponse struct {
+	RequestId   string          `json:"RequestId"`
+	Code        string          `json:"Code,omitempty"`
+	Message     string          `json:"Message,omitempty"`
+	Credentials *stsCredentials `json:"Credentials,omitempty"`
+}
+
+// assumeRole calls the STS AssumeRoleWithOIDC API, which is anonymous and
+// therefore not covered by the signers of the alibaba-cloud sdk
+func (in *SAEAPIServerOIDCCredential) assumeRole(ctx context.Context) (*stsCredentials, error) {
+	tokenFile := in.OIDCTokenFile
+	if tokenFile == "" {
+		tokenFile = os.Getenv(envOIDCTokenFile)
+	}
+	if tokenFile == "" {
+		return nil, fmt.Errorf("no oidc token file configured")
+	}
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read oidc token: %w", err)
+	}
+	endpoint, sessionName := in.STSEndpoint, in.RoleSessionName
+	if endpoint == "" {
+		endpoint = defaultSTSEndpoint
+	} else if host, ok := endpointHost(endpoint); !ok || !isAllowedEndpoint(host) {
+		return nil, fmt.Errorf("sts endpoint %s is not allowed by the proxy", endpoint)
+	}
+	if sessionName == "" {
+		sessionName = defaultRoleSessionName
+	}
+	query := url.Values{}
+	query.Set("Action", "AssumeRoleWithOIDC")
+	query.Set("Format", "JSON")
+	query.Set("Version", "2015-04-01")
+	query.Set("Timestamp", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+	form := url.Values{}
+	form.Set("RoleArn", in.RoleArn)
+	form.Set("OIDCProviderArn", in.OIDCProviderArn)
+	form.Set("OIDCToken", strings.TrimSpace(string(token)))
+	form.Set("RoleSessionName", sessionName)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+endpoint+"/?"+query.Encode(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot assume role with oidc: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	out := &assumeRoleWithOIDCResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return nil, fmt.Errorf("cannot decode AssumeRoleWithOIDC response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || out.Credentials == nil {
+		return nil, fmt.Errorf("cannot assume role with oidc: [%s] %s (request id: %s)", out.Code, out.Message, out.RequestId)
+	}
+	return out.Credentials, nil
+}
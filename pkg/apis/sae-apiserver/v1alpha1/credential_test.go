@@ -0,0 +1,169 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kubevela/pkg/util/singleton"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestValidateEndpoint(t *testing.T) {
+	cases := map[string]struct {
+		endpoint string
+		valid    bool
+	}{
+		"empty":        {valid: true},
+		"aliyuncs":     {endpoint: "sae.cn-hangzhou.aliyuncs.com", valid: true},
+		"port":         {endpoint: "sae.cn-hangzhou.aliyuncs.com:443", valid: true},
+		"vpc":          {endpoint: "sae-vpc.cn-hangzhou.aliyuncs.com", valid: true},
+		"bare domain":  {endpoint: "aliyuncs.com"},
+		"other host":   {endpoint: "attacker.example.com"},
+		"suffix trick": {endpoint: "sae.aliyuncs.com.example.com"},
+		"lookalike":    {endpoint: "evilaliyuncs.com"},
+		"ip":           {endpoint: "10.0.0.1"},
+		"scheme":       {endpoint: "https://sae.cn-hangzhou.aliyuncs.com"},
+		"path":         {endpoint: "sae.cn-hangzhou.aliyuncs.com/pop"},
+		"userinfo":     {endpoint: "user@sae.cn-hangzhou.aliyuncs.com"},
+		"invalid port": {endpoint: "sae.cn-hangzhou.aliyuncs.com:0"},
+		"upper case":   {endpoint: "SAE.cn-hangzhou.aliyuncs.com"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if errs := ValidateEndpoint(c.endpoint, field.NewPath("spec", "endpoint")); (len(errs) == 0) != c.valid {
+				t.Fatalf("expected valid %t, got %v", c.valid, errs)
+			}
+		})
+	}
+}
+
+func TestValidateEndpointAllowList(t *testing.T) {
+	defer func(endpoints []string) { allowedEndpoints = endpoints }(allowedEndpoints)
+	allowedEndpoints = []string{"sae.internal", "*.example.com"}
+	for endpoint, valid := range map[string]bool{
+		"sae.internal":        true,
+		"other.sae.internal":  false,
+		"sae.example.com":     true,
+		"sae.cn.aliyuncs.com": false,
+		"example.com":         false,
+	} {
+		if errs := ValidateEndpoint(endpoint, field.NewPath("spec", "endpoint")); (len(errs) == 0) != valid {
+			t.Fatalf("expected %s valid %t, got %v", endpoint, valid, errs)
+		}
+	}
+}
+
+func TestSAEAPIServerValidateRejects(t *testing.T) {
+	cases := map[string]struct {
+		spec  SAEAPIServerSpec
+		field string
+	}{
+		"endpoint": {
+			spec:  SAEAPIServerSpec{SAEAPIServerCredential: SAEAPIServerCredential{AccessKeyId: "ak", AccessKeySecret: "sk"}, Endpoint: "attacker.example.com"},
+			field: "spec.endpoint",
+		},
+		"sts endpoint": {
+			spec: SAEAPIServerSpec{SAEAPIServerCredential: SAEAPIServerCredential{OIDC: &SAEAPIServerOIDCCredential{
+				RoleArn: "acs:ram::1:role/sae", OIDCProviderArn: "acs:ram::1:oidc-provider/ack", STSEndpoint: "attacker.example.com",
+			}}},
+			field: "spec.oidc.stsEndpoint",
+		},
+		"secretRef namespace": {
+			spec:  SAEAPIServerSpec{SAEAPIServerCredential: SAEAPIServerCredential{SecretRef: &SAEAPIServerSecretReference{Namespace: "kube-system", Name: "admin"}}},
+			field: "spec.secretRef.namespace",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			apiserver := &SAEAPIServer{ObjectMeta: metav1.ObjectMeta{Name: "sae"}, Spec: c.spec}
+			err := apiserver.validate()
+			if err == nil {
+				t.Fatal("expected the SAEAPIServer to be rejected")
+			}
+			if !containsField(err, c.field) {
+				t.Fatalf("expected %s to be rejected, got %v", c.field, err)
+			}
+		})
+	}
+	apiserver := &SAEAPIServer{ObjectMeta: metav1.ObjectMeta{Name: "sae"}, Spec: SAEAPIServerSpec{
+		SAEAPIServerCredential: SAEAPIServerCredential{SecretRef: &SAEAPIServerSecretReference{Namespace: storageNamespace, Name: "aksk"}},
+		Endpoint:               "sae.cn-hangzhou.aliyuncs.com",
+	}}
+	if err := apiserver.validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSecretReferenceLoad(t *testing.T) {
+	newSecret := func(namespace, name string, labels map[string]string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+			Data:       map[string][]byte{IdentAccessKeyId: []byte("ak"), IdentAccessKeySecret: []byte("sk")},
+		}
+	}
+	singleton.StaticClient.Set(kubefake.NewSimpleClientset(
+		newSecret(storageNamespace, "aksk", nil),
+		newSecret("kube-system", "admin", nil),
+		newSecret(storageNamespace, "other-apiserver", map[string]string{LabelSAEAPIServer: LabelKeySAEAPIServer}),
+		newSecret(storageNamespace, saeCredentialSecretPrefix+"prod", map[string]string{LabelSAECredential: LabelKeySAEAPIServer}),
+		newSecret(storageNamespace, certificateSecretName, nil),
+		newSecret(storageNamespace, auditKeySecretName, nil),
+	))
+	cases := map[string]struct {
+		ref   SAEAPIServerSecretReference
+		valid bool
+	}{
+		"storage namespace":    {ref: SAEAPIServerSecretReference{Name: "aksk"}, valid: true},
+		"explicit namespace":   {ref: SAEAPIServerSecretReference{Namespace: storageNamespace, Name: "aksk"}, valid: true},
+		"other namespace":      {ref: SAEAPIServerSecretReference{Namespace: "kube-system", Name: "admin"}},
+		"SAEAPIServer secret":  {ref: SAEAPIServerSecretReference{Name: "other-apiserver"}},
+		"SAECredential secret": {ref: SAEAPIServerSecretReference{Name: saeCredentialSecretPrefix + "prod"}},
+		"certificate secret":   {ref: SAEAPIServerSecretReference{Name: certificateSecretName}},
+		"audit key secret":     {ref: SAEAPIServerSecretReference{Name: auditKeySecretName}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ak, sk, err := c.ref.load(context.Background())
+			if c.valid && (err != nil || ak != "ak" || sk != "sk") {
+				t.Fatalf("expected the AK/SK, got %s %s %v", ak, sk, err)
+			}
+			if !c.valid && err == nil {
+				t.Fatal("expected the secret to be refused")
+			}
+		})
+	}
+}
+
+// containsField tells if the invalid error is caused by the field
+func containsField(err error, field string) bool {
+	status, ok := err.(apierrors.APIStatus)
+	if !ok || status.Status().Details == nil {
+		return false
+	}
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Field == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// discoveryCacheSweepSize is the number of cached discovery responses from which
+// the expired ones are swept
+const discoveryCacheSweepSize = 1024
+
+var discoveryCacheRequests = metrics.NewCounterVec(&metrics.CounterOpts{
+	Namespace:      "sae_apiserver_proxy",
+	Subsystem:      "discovery_cache",
+	Name:           "requests_total",
+	Help:           "Number of the discovery requests served from the cache, partitioned by the result of hit, miss or not_modified.",
+	StabilityLevel: metrics.ALPHA,
+}, []string{"result"})
+
+func init() {
+	legacyregistry.MustRegister(discoveryCacheRequests)
+}
+
+// isDiscoveryRequest tells if the request reads the discovery or the OpenAPI
+// of the SAE APIServer, such as /api, /apis/apps/v1 and /openapi/v2
+func isDiscoveryRequest(req *http.Request, reqPath string) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	parts := strings.Split(strings.Trim(reqPath, "/"), "/")
+	switch parts[0] {
+	case "api":
+		return len(parts) <= 2
+	case "apis":
+		return len(parts) <= 3
+	case "openapi":
+		return len(parts) >= 2
+	default:
+		return false
+	}
+}
+
+// noCache tells if the client asks to bypass the cached discovery, such as by
+// kubectl api-resources --cached=false
+func noCache(req *http.Request) bool {
+	for _, value := range req.Header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			switch strings.ToLower(strings.TrimSpace(directive)) {
+			case "no-cache", "no-store", "max-age=0":
+				return true
+			}
+		}
+	}
+	return req.Header.Get("Pragma") == "no-cache"
+}
+
+// discoveryCache keeps the discovery responses of SAE keyed by SAEAPIServer,
+// region, mapped identity, path and Accept, until the TTL is reached, the client asks for a
+// refresh, or the SAEAPIServer is updated or deleted
+// +k8s:openapi-gen=false
+type discoveryCache struct {
+	mu      sync.Mutex
+	entries map[string]*discoveryEntry
+}
+
+// +k8s:openapi-gen=false
+type discoveryEntry struct {
+	header     http.Header
+	body       []byte
+	etag       string
+	expiration time.Time
+}
+
+var proxyDiscovery = &discoveryCache{entries: map[string]*discoveryEntry{}}
+
+func (in *discoveryCache) get(key string) (*discoveryEntry, bool) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	entry, found := in.entries[key]
+	if !found || time.Now().After(entry.expiration) {
+		return nil, false
+	}
+	return entry, true
+}
+
+func (in *discoveryCache) set(key string, entry *discoveryEntry) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if now := time.Now(); len(in.entries) >= discoveryCacheSweepSize {
+		for k, e := range in.entries {
+			if now.After(e.expiration) {
+				delete(in.entries, k)
+			}
+		}
+	}
+	in.entries[key] = entry
+}
+
+// evict drops the cached discovery of the SAEAPIServer in all regions
+func (in *discoveryCache) evict(name string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for key := range in.entries {
+		if strings.HasPrefix(key, name+"/") {
+			delete(in.entries, key)
+		}
+	}
+}
+
+func (in *proxyHandler) discoveryCacheKey(req *http.Request) string {
+	return in.cacheKeyPrefix() + in.path + "#" + req.Header.Get("Accept")
+}
+
+// serveDiscovery serves the discovery from the cache, which is filled by the
+// successful responses of SAE. The ETag of the cached response is checked
+// against If-None-Match, so that clients revalidating it get 304.
+func (in *proxyHandler) serveDiscovery(writer http.ResponseWriter, req *http.Request) {
+	key := in.discoveryCacheKey(req)
+	entry, found := in.discoveryEntry(key, req)
+	if !found {
+		upstreamReq := req.Clone(req.Context())
+		upstreamReq.Header.Del("If-None-Match")
+		upstreamReq.Header.Del("If-Modified-Since")
+		if in.apiserver.Spec.Proxy.filtersResources() && !strings.HasPrefix(in.path, "/openapi") {
+			// filtered in json, and re-encoded as accepted afterwards
+			upstreamReq.Header.Set("Accept", mediaTypeJSON)
+		}
+		response, err := in.RoundTrip(upstreamReq)
+		if err == nil && response.StatusCode == http.StatusOK {
+			response, err = in.filterDiscovery(response)
+		}
+		if err != nil {
+			in.responder.Error(err)
+			return
+		}
+		defer func() { _ = response.Body.Close() }()
+		if response.StatusCode != http.StatusOK || discoveryCacheTTL <= 0 {
+			in.writeDiscovery(writer, req, response)
+			return
+		}
+		if entry, err = newDiscoveryEntry(response); err != nil {
+			in.responder.Error(err)
+			return
+		}
+		proxyDiscovery.set(key, entry)
+	}
+	if entry.etag != "" && req.Header.Get("If-None-Match") == entry.etag {
+		discoveryCacheRequests.WithLabelValues("not_modified").Inc()
+		writer.Header().Set("ETag", entry.etag)
+		writer.WriteHeader(http.StatusNotModified)
+		return
+	}
+	in.writeDiscovery(writer, req, entry.response(req))
+}
+
+// discoveryEntry looks up the cache unless the client asks for a refresh
+func (in *proxyHandler) discoveryEntry(key string, req *http.Request) (*discoveryEntry, bool) {
+	if discoveryCacheTTL <= 0 || noCache(req) {
+		discoveryCacheRequests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+	entry, found := proxyDiscovery.get(key)
+	if found {
+		discoveryCacheRequests.WithLabelValues("hit").Inc()
+	} else {
+		discoveryCacheRequests.WithLabelValues("miss").Inc()
+	}
+	return entry, found
+}
+
+func (in *proxyHandler) writeDiscovery(writer http.ResponseWriter, req *http.Request, response *http.Response) {
+	response, err := encodeResponse(req, response)
+	if err != nil {
+		in.responder.Error(err)
+		return
+	}
+	in.writeResponse(writer, req, response)
+}
+
+// newDiscoveryEntry reads the response of SAE into the cache entry, with the
+// ETag of the upstream or the digest of the body
+func newDiscoveryEntry(response *http.Response) (*discoveryEntry, error) {
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	entry := &discoveryEntry{
+		header:     response.Header.Clone(),
+		body:       body,
+		etag:       response.Header.Get("ETag"),
+		expiration: time.Now().Add(discoveryCacheTTL),
+	}
+	if entry.header == nil {
+		entry.header = http.Header{}
+	}
+	if entry.etag == "" {
+		sum := sha256.Sum256(body)
+		entry.etag = strconv.Quote(hex.EncodeToString(sum[:16]))
+		entry.header.Set("ETag", entry.etag)
+	}
+	entry.header.Set("Content-Length", strconv.Itoa(len(body)))
+	return entry, nil
+}
+
+// response builds a new response of the cached discovery for the request
+func (in *discoveryEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Proto:         req.Proto,
+		ProtoMajor:    req.ProtoMajor,
+		ProtoMinor:    req.ProtoMinor,
+		Request:       req,
+		Header:        in.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(in.body)),
+		ContentLength: int64(len(in.body)),
+	}
+}
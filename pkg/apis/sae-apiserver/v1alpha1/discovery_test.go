@@ -0,0 +1,174 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestDiscoveryCacheKey(t *testing.T) {
+	apiserver := &SAEAPIServer{}
+	apiserver.Name = "sae"
+	req := httptest.NewRequest(http.MethodGet, "/apis", nil)
+	req.Header.Set("Accept", mediaTypeJSON)
+	keys := sets.NewString()
+	for _, handler := range []*proxyHandler{
+		{apiserver: apiserver, region: "cn-hangzhou", path: "/apis"},
+		{apiserver: apiserver, region: "cn-hangzhou", path: "/apis", identity: "0"},
+		{apiserver: apiserver, region: "cn-hangzhou", path: "/apis", identity: "1"},
+		{apiserver: apiserver, region: "cn-shanghai", path: "/apis"},
+		{apiserver: apiserver, region: "cn-hangzhou", path: "/api"},
+	} {
+		keys.Insert(handler.discoveryCacheKey(req))
+	}
+	if keys.Len() != 5 {
+		t.Fatalf("expected the keys to tell apart the region, identity and path, got %v", keys.List())
+	}
+}
+
+func TestIsDiscoveryRequest(t *testing.T) {
+	cases := map[string]bool{
+		"/api":                      true,
+		"/api/v1":                   true,
+		"/apis":                     true,
+		"/apis/apps":                true,
+		"/apis/apps/v1":             true,
+		"/openapi/v2":               true,
+		"/openapi/v3/apis/apps/v1":  true,
+		"/openapi":                  false,
+		"/api/v1/pods":              false,
+		"/apis/apps/v1/deployments": false,
+		"/version":                  false,
+	}
+	for reqPath, expected := range cases {
+		if discovery := isDiscoveryRequest(httptest.NewRequest(http.MethodGet, reqPath, nil), reqPath); discovery != expected {
+			t.Fatalf("expected %s discovery %t, got %t", reqPath, expected, discovery)
+		}
+	}
+	if isDiscoveryRequest(httptest.NewRequest(http.MethodPost, "/api", nil), "/api") {
+		t.Fatal("expected POST not to be discovery")
+	}
+}
+
+func TestNoCache(t *testing.T) {
+	cases := map[string]struct {
+		header   http.Header
+		expected bool
+	}{
+		"none":      {header: http.Header{}},
+		"no-cache":  {header: http.Header{"Cache-Control": {"no-cache"}}, expected: true},
+		"no-store":  {header: http.Header{"Cache-Control": {"private, No-Store"}}, expected: true},
+		"max-age=0": {header: http.Header{"Cache-Control": {"max-age=0"}}, expected: true},
+		"max-age":   {header: http.Header{"Cache-Control": {"max-age=60"}}},
+		"pragma":    {header: http.Header{"Pragma": {"no-cache"}}, expected: true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/apis", nil)
+			req.Header = c.header
+			if cached := noCache(req); cached != c.expected {
+				t.Fatalf("expected no-cache %t, got %t", c.expected, cached)
+			}
+		})
+	}
+}
+
+func TestNewDiscoveryEntryETag(t *testing.T) {
+	body := []byte(`{"kind":"APIVersions","versions":["v1"]}`)
+	newResponse := func(header http.Header) *http.Response {
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewReader(body))}
+	}
+	upstream, err := newDiscoveryEntry(newResponse(http.Header{"Etag": {`"upstream"`}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upstream.etag != `"upstream"` {
+		t.Fatalf("expected the ETag of the upstream, got %s", upstream.etag)
+	}
+	digest, err := newDiscoveryEntry(newResponse(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(digest.etag) != 34 || !strings.HasPrefix(digest.etag, `"`) || digest.header.Get("ETag") != digest.etag {
+		t.Fatalf("expected the quoted digest as ETag, got %s", digest.etag)
+	}
+	if digest.header.Get("Content-Length") != "40" || !bytes.Equal(digest.body, body) {
+		t.Fatalf("unexpected entry %+v", digest)
+	}
+	same, _ := newDiscoveryEntry(newResponse(nil))
+	if same.etag != digest.etag {
+		t.Fatalf("expected the same ETag of the same body, got %s and %s", digest.etag, same.etag)
+	}
+}
+
+func TestServeDiscoveryCache(t *testing.T) {
+	fake := newFakeSAE(t)
+	defer fake.Close()
+	body := []byte(`{"kind":"APIVersions","versions":["v1"]}`)
+	fake.response, _ = json.Marshal(map[string]interface{}{
+		"code":   http.StatusOK,
+		"body":   body,
+		"header": map[string][]string{"Content-Type": {mediaTypeJSON}},
+	})
+	handler := fake.newHandler(t, nil)
+	handler.apiserver.Name, handler.path = "discovery-cache", "/api"
+	proxyDiscovery.evict(handler.apiserver.Name)
+	defer proxyDiscovery.evict(handler.apiserver.Name)
+	responder := &errorResponder{}
+	handler.responder = responder
+	serve := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.Header = header
+		req.Header.Set("Accept", mediaTypeJSON)
+		recorder := httptest.NewRecorder()
+		handler.serveDiscovery(recorder, req)
+		if responder.err != nil {
+			t.Fatal(responder.err)
+		}
+		return recorder
+	}
+
+	first := serve(http.Header{})
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || !bytes.Equal(first.Body.Bytes(), body) || etag == "" || fake.requests != 1 {
+		t.Fatalf("unexpected response %d %q with ETag %q after %d requests", first.Code, first.Body.Bytes(), etag, fake.requests)
+	}
+	if cached := serve(http.Header{}); cached.Code != http.StatusOK || !bytes.Equal(cached.Body.Bytes(), body) || fake.requests != 1 {
+		t.Fatalf("expected the cached response, got %d %q after %d requests", cached.Code, cached.Body.Bytes(), fake.requests)
+	}
+	if revalidated := serve(http.Header{"If-None-Match": {etag}}); revalidated.Code != http.StatusNotModified || revalidated.Body.Len() != 0 || fake.requests != 1 {
+		t.Fatalf("expected 304, got %d after %d requests", revalidated.Code, fake.requests)
+	}
+	if changed := serve(http.Header{"If-None-Match": {`"stale"`}}); changed.Code != http.StatusOK || fake.requests != 1 {
+		t.Fatalf("expected 200 for a stale ETag, got %d", changed.Code)
+	}
+	if refreshed := serve(http.Header{"Cache-Control": {"no-cache"}}); refreshed.Code != http.StatusOK || fake.requests != 2 {
+		t.Fatalf("expected the cache to be bypassed, got %d after %d requests", refreshed.Code, fake.requests)
+	}
+	proxyDiscovery.evict(handler.apiserver.Name)
+	if serve(http.Header{}); fake.requests != 3 {
+		t.Fatalf("expected the evicted cache to be refilled, got %d requests", fake.requests)
+	}
+}
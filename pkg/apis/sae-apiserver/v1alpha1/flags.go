@@ -17,12 +17,47 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"time"
+
 	"github.com/spf13/pflag"
 )
 
 var (
-	storageNamespace = "vela-system"
-	serverAddress    = "http://localhost:9443"
+	storageNamespace  = "vela-system"
+	serverAddress     = "http://localhost:9443"
+	allowedEndpoints  = []string{"*.aliyuncs.com"}
+	reconcileInterval = time.Minute
+	historyLimit      = 10
+	maxResponseSize   = int64(32 << 20)
+
+	compressionThreshold = int64(128 << 10)
+
+	retryMaxRetries     = 3
+	retryInitialBackoff = 200 * time.Millisecond
+	retryMaxBackoff     = 5 * time.Second
+
+	rateLimitQPS          = 0
+	rateLimitBurst        = 10
+	rateLimitPerUser      = false
+	rateLimitQueueTimeout = 5 * time.Second
+
+	clientConnectTimeout  = 5 * time.Second
+	clientReadTimeout     = 10 * time.Second
+	clientMaxIdleConns    = 16
+	clientIdleConnTimeout = 90 * time.Second
+
+	watchPollInterval     = 5 * time.Second
+	watchBookmarkInterval = time.Minute
+	watchTimeout          = 30 * time.Minute
+	logPollInterval       = 2 * time.Second
+	discoveryCacheTTL     = 5 * time.Minute
+
+	selfManagedCerts      = false
+	certificateSecretName = "sae-apiserver-proxy-certs"
+	certificateHosts      []string
+	certificateValidity   = 365 * 24 * time.Hour
+
+	auditKeySecretName = "sae-apiserver-proxy-audit-key"
 )
 
 func AddFlags(set *pflag.FlagSet) {
@@ -30,4 +65,56 @@ func AddFlags(set *pflag.FlagSet) {
 		"The namespace that holds sae cluster secrets.")
 	set.StringVarP(&serverAddress, "server-address", "", serverAddress,
 		"The server address for access this proxy.")
+	set.StringSliceVarP(&allowedEndpoints, "allowed-endpoints", "", allowedEndpoints,
+		"The hosts the SAE OpenAPI and STS endpoints of SAEAPIServers may override, *.<domain> for the subdomains.")
+	set.DurationVarP(&reconcileInterval, "reconcile-interval", "", reconcileInterval,
+		"The interval for syncing the cluster-gateway metadata of sae cluster secrets.")
+	set.IntVarP(&historyLimit, "history-limit", "", historyLimit,
+		"The number of changes kept in the history of each SAEAPIServer.")
+	set.Int64VarP(&maxResponseSize, "max-response-size", "", maxResponseSize,
+		"The max size in bytes of the decoded response proxied from SAE, 0 for unlimited. Larger envelopes of SAE are rejected before decoding.")
+	set.Int64VarP(&compressionThreshold, "compression-threshold", "", compressionThreshold,
+		"The size in bytes from which the response to gzip clients, and the request content sent to SAE if spec.proxy.compressRequests is set, are compressed, 0 to disable.")
+	set.IntVarP(&retryMaxRetries, "retry-max-retries", "", retryMaxRetries,
+		"The default max retries of the idempotent requests on SAE throttling and transient errors, 0 to disable.")
+	set.DurationVarP(&retryInitialBackoff, "retry-initial-backoff", "", retryInitialBackoff,
+		"The default backoff before the first retry, doubled for each retry and jittered.")
+	set.DurationVarP(&retryMaxBackoff, "retry-max-backoff", "", retryMaxBackoff,
+		"The default max backoff between retries, Retry-After longer than it is not waited for.")
+	set.IntVarP(&rateLimitQPS, "rate-limit-qps", "", rateLimitQPS,
+		"The default QPS of the requests proxied to each SAEAPIServer, 0 for unlimited.")
+	set.IntVarP(&rateLimitBurst, "rate-limit-burst", "", rateLimitBurst,
+		"The default burst of the requests proxied to each SAEAPIServer.")
+	set.BoolVarP(&rateLimitPerUser, "rate-limit-per-user", "", rateLimitPerUser,
+		"Limit each caller of a SAEAPIServer by a token bucket of its own as well by default.")
+	set.DurationVarP(&rateLimitQueueTimeout, "rate-limit-queue-timeout", "", rateLimitQueueTimeout,
+		"The default time over-limit requests wait for a token before rejected with 429, 0 to reject at once.")
+	set.DurationVarP(&clientConnectTimeout, "client-connect-timeout", "", clientConnectTimeout,
+		"The timeout of connecting to SAE.")
+	set.DurationVarP(&clientReadTimeout, "client-read-timeout", "", clientReadTimeout,
+		"The timeout of each request to SAE.")
+	set.IntVarP(&clientMaxIdleConns, "client-max-idle-conns", "", clientMaxIdleConns,
+		"The max idle keep-alive connections to SAE kept by the client of each SAEAPIServer and region.")
+	set.DurationVarP(&clientIdleConnTimeout, "client-idle-conn-timeout", "", clientIdleConnTimeout,
+		"The time an idle keep-alive connection to SAE is kept before closed.")
+	set.DurationVarP(&watchPollInterval, "watch-poll-interval", "", watchPollInterval,
+		"The interval for polling the list through SAE when emulating watch.")
+	set.DurationVarP(&watchBookmarkInterval, "watch-bookmark-interval", "", watchBookmarkInterval,
+		"The interval for sending bookmarks to the emulated watch that allows them.")
+	set.DurationVarP(&watchTimeout, "watch-timeout", "", watchTimeout,
+		"The timeout of the emulated watch when timeoutSeconds is not set.")
+	set.DurationVarP(&logPollInterval, "log-poll-interval", "", logPollInterval,
+		"The interval for polling the pod log through SAE when emulating follow.")
+	set.DurationVarP(&discoveryCacheTTL, "discovery-cache-ttl", "", discoveryCacheTTL,
+		"The time the discovery and OpenAPI of SAE are cached for, 0 to disable.")
+	set.BoolVarP(&selfManagedCerts, "self-managed-certs", "", selfManagedCerts,
+		"Generate and rotate the serving certificates, and inject the caBundle into the APIServices.")
+	set.StringVarP(&certificateSecretName, "cert-secret-name", "", certificateSecretName,
+		"The name of the secret in the storage namespace that holds the self-managed certificates.")
+	set.StringSliceVarP(&certificateHosts, "cert-hosts", "", certificateHosts,
+		"The extra hosts of the self-managed serving certificate, such as the service of this proxy.")
+	set.DurationVarP(&certificateValidity, "cert-validity", "", certificateValidity,
+		"The validity of the self-managed serving certificate, which is rotated when one third is left.")
+	set.StringVarP(&auditKeySecretName, "audit-key-secret-name", "", auditKeySecretName,
+		"The name of the secret in the storage namespace that holds the key of the credential fingerprints, created if missing.")
 }
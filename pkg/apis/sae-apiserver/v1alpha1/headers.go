@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"net/http"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var (
+	// hopByHopHeaders only apply to a single connection, and are never passed
+	hopByHopHeaders = newHeaderSet("Connection", "Keep-Alive", "Proxy-Connection", "Proxy-Authenticate",
+		"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade")
+	// credentialHeaders carry the credentials of the hub, which are never sent to SAE
+	credentialHeaders = newHeaderSet("Authorization", "Cookie")
+	// impersonateHeaderPrefix is the prefix of the impersonation headers of cluster-gateway
+	impersonateHeaderPrefix = "Impersonate-"
+
+	defaultRequestHeaders = newHeaderSet("Accept", "Accept-Encoding", "Accept-Language", "Content-Type", "User-Agent",
+		"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since", "Audit-Id")
+	defaultResponseHeaders = newHeaderSet("Content-Type", "Content-Length", "Content-Encoding", "Content-Disposition",
+		"Cache-Control", "Expires", "ETag", "Last-Modified", "Vary", "Warning", "Retry-After", "Location", "Audit-Id",
+		"X-Kubernetes-Pf-Flowschema-Uid", "X-Kubernetes-Pf-Prioritylevel-Uid")
+)
+
+// newHeaderSet builds the set of the canonical header keys, which are the keys
+// of http.Header, e.g. ETag is Etag
+func newHeaderSet(names ...string) sets.String {
+	set := sets.NewString()
+	for _, name := range names {
+		set.Insert(http.CanonicalHeaderKey(name))
+	}
+	return set
+}
+
+// Validate checks the extra headers, which must not be the ones never passed
+func (in *SAEAPIServerHeaderPolicy) Validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if in == nil {
+		return errs
+	}
+	validate := func(fldPath *field.Path, names []string, request bool) {
+		for i, name := range names {
+			for _, msg := range validation.IsHTTPHeaderName(name) {
+				errs = append(errs, field.Invalid(fldPath.Index(i), name, msg))
+			}
+			if key := http.CanonicalHeaderKey(name); isDeniedHeader(key, request) {
+				errs = append(errs, field.Forbidden(fldPath.Index(i), key+" is never passed through the proxy"))
+			}
+		}
+	}
+	validate(fldPath.Child("request"), in.Request, true)
+	validate(fldPath.Child("response"), in.Response, false)
+	return errs
+}
+
+// isDeniedHeader tells if the canonical header is never passed
+func isDeniedHeader(key string, request bool) bool {
+	if hopByHopHeaders.Has(key) {
+		return true
+	}
+	return request && (credentialHeaders.Has(key) || strings.HasPrefix(key, impersonateHeaderPrefix))
+}
+
+func (in *SAEAPIServerProxyConfig) allowedHeaders(request bool) sets.String {
+	allowed := defaultResponseHeaders
+	if request {
+		allowed = defaultRequestHeaders
+	}
+	if in == nil || in.Headers == nil {
+		return allowed
+	}
+	extra := in.Headers.Response
+	if request {
+		extra = in.Headers.Request
+	}
+	allowed = sets.NewString(allowed.UnsortedList()...)
+	for _, name := range extra {
+		allowed.Insert(http.CanonicalHeaderKey(name))
+	}
+	return allowed
+}
+
+// filterHeader copies the allowed headers, without the hop-by-hop headers and
+// the ones listed in Connection
+func filterHeader(header http.Header, allowed sets.String, request bool) http.Header {
+	connection := sets.NewString()
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			connection.Insert(http.CanonicalHeaderKey(strings.TrimSpace(name)))
+		}
+	}
+	filtered := http.Header{}
+	for key, values := range header {
+		key = http.CanonicalHeaderKey(key)
+		if !allowed.Has(key) || connection.Has(key) || isDeniedHeader(key, request) {
+			continue
+		}
+		filtered[key] = append(filtered[key], values...)
+	}
+	return filtered
+}
+
+// filterRequestHeader keeps the request headers forwarded to SAE
+func (in *proxyHandler) filterRequestHeader(header http.Header) http.Header {
+	return filterHeader(header, in.apiserver.Spec.Proxy.allowedHeaders(true), true)
+}
+
+// filterResponseHeader keeps the response headers of SAE returned to the client
+func (in *proxyHandler) filterResponseHeader(header http.Header) http.Header {
+	return filterHeader(header, in.apiserver.Spec.Proxy.allowedHeaders(false), false)
+}
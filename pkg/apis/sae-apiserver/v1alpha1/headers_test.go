@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestFilterRequestHeader(t *testing.T) {
+	header := http.Header{
+		"Accept":             {"application/json"},
+		"If-None-Match":      {`"etag"`},
+		"Authorization":      {"Bearer hub"},
+		"Cookie":             {"session=hub"},
+		"Impersonate-User":   {"admin"},
+		"Impersonate-Group":  {"system:masters"},
+		"Connection":         {"keep-alive, X-Trace"},
+		"Keep-Alive":         {"timeout=5"},
+		"Transfer-Encoding":  {"chunked"},
+		"Upgrade":            {"SPDY/3.1"},
+		"X-Trace":            {"1"},
+		"X-Request-Source":   {"hub"},
+		"x-lowercase-custom": {"1"},
+	}
+	cases := map[string]struct {
+		config   *SAEAPIServerProxyConfig
+		expected http.Header
+	}{
+		"default": {expected: http.Header{
+			"Accept":        {"application/json"},
+			"If-None-Match": {`"etag"`},
+		}},
+		"extra": {
+			config: &SAEAPIServerProxyConfig{Headers: &SAEAPIServerHeaderPolicy{
+				Request: []string{"x-request-source", "X-Trace", "X-Lowercase-Custom", "Authorization", "Impersonate-User"},
+			}},
+			expected: http.Header{
+				"Accept":             {"application/json"},
+				"If-None-Match":      {`"etag"`},
+				"X-Request-Source":   {"hub"},
+				"X-Lowercase-Custom": {"1"},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			handler := &proxyHandler{apiserver: &SAEAPIServer{Spec: SAEAPIServerSpec{Proxy: c.config}}}
+			if filtered := handler.filterRequestHeader(header); !reflect.DeepEqual(filtered, c.expected) {
+				t.Fatalf("expected %v, got %v", c.expected, filtered)
+			}
+		})
+	}
+}
+
+func TestFilterResponseHeader(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("ETag", `"etag"`)
+	header.Set("Retry-After", "1")
+	header.Set("Audit-Id", "id")
+	header.Set("Set-Cookie", "session=sae")
+	header.Set("Transfer-Encoding", "chunked")
+	header.Set("X-Acs-Request-Id", "sae")
+	cases := map[string]struct {
+		config   *SAEAPIServerProxyConfig
+		expected []string
+	}{
+		"default": {expected: []string{"Audit-Id", "Content-Type", "Etag", "Retry-After"}},
+		"extra": {
+			config:   &SAEAPIServerProxyConfig{Headers: &SAEAPIServerHeaderPolicy{Response: []string{"x-acs-request-id", "Transfer-Encoding"}}},
+			expected: []string{"Audit-Id", "Content-Type", "Etag", "Retry-After", "X-Acs-Request-Id"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			handler := &proxyHandler{apiserver: &SAEAPIServer{Spec: SAEAPIServerSpec{Proxy: c.config}}}
+			filtered := handler.filterResponseHeader(header)
+			var keys []string
+			for key := range filtered {
+				keys = append(keys, key)
+			}
+			if !newHeaderSet(keys...).Equal(newHeaderSet(c.expected...)) || len(keys) != len(c.expected) {
+				t.Fatalf("expected %v, got %v", c.expected, filtered)
+			}
+			if filtered.Get("ETag") != `"etag"` {
+				t.Fatalf("expected the ETag to be kept, got %v", filtered)
+			}
+		})
+	}
+}
+
+func TestAllowedHeadersDoesNotChangeDefaults(t *testing.T) {
+	config := &SAEAPIServerProxyConfig{Headers: &SAEAPIServerHeaderPolicy{Request: []string{"X-Trace"}}}
+	if !config.allowedHeaders(true).Has("X-Trace") {
+		t.Fatal("expected the extra request header to be allowed")
+	}
+	if defaultRequestHeaders.Has("X-Trace") || config.allowedHeaders(false).Has("X-Trace") {
+		t.Fatal("expected the extra request header only in the request allow-list")
+	}
+}
+
+func TestHeaderPolicyValidate(t *testing.T) {
+	cases := map[string]struct {
+		policy *SAEAPIServerHeaderPolicy
+		errs   int
+	}{
+		"nil":                  {},
+		"valid":                {policy: &SAEAPIServerHeaderPolicy{Request: []string{"X-Trace"}, Response: []string{"X-Acs-Request-Id"}}},
+		"invalid name":         {policy: &SAEAPIServerHeaderPolicy{Request: []string{"X Trace"}}, errs: 1},
+		"hop-by-hop request":   {policy: &SAEAPIServerHeaderPolicy{Request: []string{"connection", "Upgrade"}}, errs: 2},
+		"hop-by-hop response":  {policy: &SAEAPIServerHeaderPolicy{Response: []string{"Transfer-Encoding"}}, errs: 1},
+		"credential":           {policy: &SAEAPIServerHeaderPolicy{Request: []string{"authorization", "Cookie"}}, errs: 2},
+		"impersonation":        {policy: &SAEAPIServerHeaderPolicy{Request: []string{"Impersonate-Extra-Scopes"}}, errs: 1},
+		"credential responses": {policy: &SAEAPIServerHeaderPolicy{Response: []string{"Authorization"}}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if errs := c.policy.Validate(field.NewPath("headers")); len(errs) != c.errs {
+				t.Fatalf("expected %d errors, got %v", c.errs, errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/strings/slices"
+)
+
+// Validate checks the identity mapping
+func (in SAEAPIServerIdentityMapping) Validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if len(in.Users) == 0 && len(in.Groups) == 0 {
+		errs = append(errs, field.Required(fldPath, "at least one of users and groups must be set"))
+	}
+	if in.CredentialRef == nil && in.RoleArn == "" {
+		errs = append(errs, field.Required(fldPath, "at least one of credentialRef and roleArn must be set"))
+	}
+	if in.CredentialRef != nil && in.CredentialRef.Name == "" {
+		errs = append(errs, field.Required(fldPath.Child("credentialRef", "name"), ""))
+	}
+	if in.Policy != "" && in.RoleArn == "" {
+		errs = append(errs, field.Invalid(fldPath.Child("policy"), in.Policy, "only applies to the assumed roleArn"))
+	}
+	return errs
+}
+
+func (in SAEAPIServerIdentityMapping) matches(u user.Info) bool {
+	if slices.Contains(in.Users, u.GetName()) {
+		return true
+	}
+	for _, group := range u.GetGroups() {
+		if slices.Contains(in.Groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchIdentityMapping returns the index of the first mapping matching the
+// caller, -1 if none matches
+func (in *SAEAPIServer) matchIdentityMapping(ctx context.Context) int {
+	u, ok := request.UserFrom(ctx)
+	if !ok {
+		return -1
+	}
+	for i, mapping := range in.Spec.IdentityMappings {
+		if mapping.matches(u) {
+			return i
+		}
+	}
+	return -1
+}
+
+// resolveIdentityCredential resolves the credential mapped from the caller,
+// which is the impersonated user if cluster-gateway passes the identity of the
+// hub with the Impersonate-* headers, as they are applied before the proxy.
+// The identity tells apart the clients of the mappings, empty for the default.
+func (in *SAEAPIServer) resolveIdentityCredential(ctx context.Context) (cred *SAEAPIServerCredential, identity string, err error) {
+	i := in.matchIdentityMapping(ctx)
+	if i < 0 {
+		cred, err = in.resolveCredential(ctx)
+		return cred, "", err
+	}
+	mapping := in.Spec.IdentityMappings[i]
+	if mapping.CredentialRef != nil {
+		credential, err := getSAECredential(ctx, mapping.CredentialRef.Name)
+		if err != nil {
+			return nil, "", fmt.Errorf("cannot load SAECredential %s: %w", mapping.CredentialRef.Name, err)
+		}
+		cred = &SAEAPIServerCredential{AccessKeyId: credential.Spec.AccessKeyId, AccessKeySecret: credential.Spec.AccessKeySecret}
+	} else if cred, err = in.resolveCredential(ctx); err != nil {
+		return nil, "", err
+	}
+	if mapping.RoleArn != "" {
+		cred = cred.withRole(mapping.RoleArn, mapping.Policy)
+	}
+	if u, ok := request.UserFrom(ctx); ok {
+		klog.V(4).InfoS("mapped caller to SAE identity", "SAEAPIServer", in.Name, "user", u.GetName(), "mapping", i)
+	}
+	return cred, strconv.Itoa(i), nil
+}
+
+// withRole returns the resolved credential assuming the role instead, with the
+// AK/SK of the credential, or the OIDC token of the proxy
+func (in *SAEAPIServerCredential) withRole(roleArn string, policy string) *SAEAPIServerCredential {
+	switch in.GetType() {
+	case CredentialTypeSTS:
+		sts := *in.STS
+		sts.RoleArn, sts.Policy = roleArn, policy
+		return &SAEAPIServerCredential{STS: &sts}
+	case CredentialTypeOIDC:
+		oidc := *in.OIDC
+		oidc.RoleArn = roleArn
+		return &SAEAPIServerCredential{OIDC: &oidc}
+	default:
+		return &SAEAPIServerCredential{STS: &SAEAPIServerSTSCredential{
+			AccessKeyId:     in.AccessKeyId,
+			AccessKeySecret: in.AccessKeySecret,
+			RoleArn:         roleArn,
+			Policy:          policy,
+		}}
+	}
+}
+
+// CredentialRefs returns the references to the SAECredentials, of the spec and
+// of the identity mappings
+func (in *SAEAPIServerSpec) CredentialRefs() []*SAECredentialReference {
+	var refs []*SAECredentialReference
+	if in.CredentialRef != nil {
+		refs = append(refs, in.CredentialRef)
+	}
+	for i := range in.IdentityMappings {
+		if ref := in.IdentityMappings[i].CredentialRef; ref != nil {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
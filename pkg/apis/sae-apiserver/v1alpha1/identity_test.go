@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestMatchIdentityMapping(t *testing.T) {
+	apiserver := &SAEAPIServer{Spec: SAEAPIServerSpec{IdentityMappings: []SAEAPIServerIdentityMapping{
+		{Users: []string{"alice"}, RoleArn: "acs:ram::1:role/alice"},
+		{Groups: []string{"dev", "ops"}, RoleArn: "acs:ram::1:role/dev"},
+		{Users: []string{"bob"}, Groups: []string{"ops"}, RoleArn: "acs:ram::1:role/bob"},
+	}}}
+	cases := map[string]struct {
+		user     user.Info
+		expected int
+	}{
+		"anonymous":         {expected: -1},
+		"user":              {user: &user.DefaultInfo{Name: "alice", Groups: []string{"ops"}}, expected: 0},
+		"group":             {user: &user.DefaultInfo{Name: "carol", Groups: []string{"system:authenticated", "ops"}}, expected: 1},
+		"first match wins":  {user: &user.DefaultInfo{Name: "bob", Groups: []string{"dev"}}, expected: 1},
+		"later user":        {user: &user.DefaultInfo{Name: "bob"}, expected: 2},
+		"no match":          {user: &user.DefaultInfo{Name: "dave", Groups: []string{"qa"}}, expected: -1},
+		"group is not user": {user: &user.DefaultInfo{Name: "dev"}, expected: -1},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if c.user != nil {
+				ctx = request.WithUser(ctx, c.user)
+			}
+			if i := apiserver.matchIdentityMapping(ctx); i != c.expected {
+				t.Fatalf("expected mapping %d, got %d", c.expected, i)
+			}
+		})
+	}
+}
+
+func TestIdentityMappingValidate(t *testing.T) {
+	cases := map[string]struct {
+		mapping SAEAPIServerIdentityMapping
+		errs    int
+	}{
+		"valid":          {mapping: SAEAPIServerIdentityMapping{Users: []string{"alice"}, CredentialRef: &SAECredentialReference{Name: "alice"}}},
+		"no subject":     {mapping: SAEAPIServerIdentityMapping{RoleArn: "acs:ram::1:role/alice"}, errs: 1},
+		"no credential":  {mapping: SAEAPIServerIdentityMapping{Groups: []string{"dev"}}, errs: 1},
+		"empty ref":      {mapping: SAEAPIServerIdentityMapping{Groups: []string{"dev"}, CredentialRef: &SAECredentialReference{}}, errs: 1},
+		"policy no role": {mapping: SAEAPIServerIdentityMapping{Groups: []string{"dev"}, CredentialRef: &SAECredentialReference{Name: "dev"}, Policy: "{}"}, errs: 1},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if errs := c.mapping.Validate(nil); len(errs) != c.errs {
+				t.Fatalf("expected %d errors, got %v", c.errs, errs)
+			}
+		})
+	}
+}
+
+func TestCredentialRefs(t *testing.T) {
+	spec := &SAEAPIServerSpec{IdentityMappings: []SAEAPIServerIdentityMapping{
+		{Users: []string{"alice"}, CredentialRef: &SAECredentialReference{Name: "alice"}},
+		{Groups: []string{"dev"}, RoleArn: "acs:ram::1:role/dev"},
+		{Groups: []string{"ops"}, CredentialRef: &SAECredentialReference{Name: "ops"}},
+	}}
+	spec.CredentialRef = &SAECredentialReference{Name: "default"}
+	var names []string
+	for _, ref := range spec.CredentialRefs() {
+		names = append(names, ref.Name)
+	}
+	if expected := []string{"default", "alice", "ops"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+	spec.CredentialRefs()[1].Name = "renamed"
+	if spec.IdentityMappings[0].CredentialRef.Name != "renamed" {
+		t.Fatalf("expected the references to be updatable in place")
+	}
+}
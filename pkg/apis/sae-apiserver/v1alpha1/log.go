@@ -0,0 +1,196 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+var podLogPathPattern = regexp.MustCompile(`/api/v1/namespaces/[^/]+/pods/[^/]+/log$`)
+
+// logQueryKeysToDrop are the query parameters of the first log request, which
+// are replaced by sinceTime when polling afterwards
+var logQueryKeysToDrop = []string{"follow", "tailLines", "sinceSeconds", "sinceTime", "limitBytes"}
+
+func isFollowLogRequest(req *http.Request, reqPath string) bool {
+	if req.Method != http.MethodGet || !podLogPathPattern.MatchString(reqPath) {
+		return false
+	}
+	follow, _ := strconv.ParseBool(req.URL.Query().Get("follow"))
+	return follow
+}
+
+// logCursor tracks the timestamp of the last written line, together with the
+// lines written in the same second, as sinceTime only has the precision of seconds.
+// The lines are counted, so that the same line logged twice at the same time is
+// written twice, while the lines polled again are skipped.
+// +k8s:openapi-gen=false
+type logCursor struct {
+	second time.Time
+	last   time.Time
+	// seen counts the lines written in the second
+	seen map[string]int
+	// polled counts the lines of the second in the current poll
+	polled map[string]int
+}
+
+func newLogCursor() *logCursor {
+	return &logCursor{seen: map[string]int{}, polled: map[string]int{}}
+}
+
+// poll starts reading the log polled again since the second of the cursor
+func (in *logCursor) poll() {
+	in.polled = map[string]int{}
+}
+
+// accept tells if the timestamped line is not written yet
+func (in *logCursor) accept(line string) bool {
+	ts, _, _ := strings.Cut(line, " ")
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		// lines without timestamp cannot be deduplicated
+		return true
+	}
+	if t.Before(in.last) {
+		return false
+	}
+	if second := t.Truncate(time.Second); !second.Equal(in.second) {
+		in.second, in.seen, in.polled = second, map[string]int{}, map[string]int{}
+	}
+	if in.polled[line]++; in.polled[line] <= in.seen[line] {
+		return false
+	}
+	in.seen[line]++
+	in.last = t
+	return true
+}
+
+// serveFollowLog emulates follow=true of pods/log. The log is polled with
+// timestamps and sinceTime set to the last written line, and the lines already
+// written are skipped, until the client disconnects.
+func (in *proxyHandler) serveFollowLog(writer http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	query := req.URL.Query()
+	timestamps, _ := strconv.ParseBool(query.Get("timestamps"))
+	limitBytes, _ := strconv.ParseInt(query.Get("limitBytes"), 10, 64)
+	query.Set("timestamps", "true")
+	query.Del("follow")
+	logReq := req.Clone(ctx)
+	logReq.Body = http.NoBody
+	logReq.URL.RawQuery = query.Encode()
+
+	start := time.Now()
+	response, err := in.RoundTrip(logReq)
+	if err != nil {
+		in.responder.Error(err)
+		return
+	}
+	if response.StatusCode != http.StatusOK {
+		defer func() { _ = response.Body.Close() }()
+		in.writeResponse(writer, req, response)
+		return
+	}
+	writer.Header().Set("Content-Type", "text/plain")
+	writer.WriteHeader(http.StatusOK)
+	flusher, _ := writer.(http.Flusher)
+
+	cursor := newLogCursor()
+	var written int64
+	// write copies the new lines of the response and tells if the stream goes on
+	write := func(response *http.Response) bool {
+		defer func() { _ = response.Body.Close() }()
+		cursor.poll()
+		reader := bufio.NewReaderSize(response.Body, proxyBufferSize)
+		for {
+			line, err := reader.ReadString('\n')
+			if line = strings.TrimSuffix(line, "\n"); line != "" && cursor.accept(line) {
+				if !timestamps {
+					if _, msg, found := strings.Cut(line, " "); found {
+						line = msg
+					}
+				}
+				data := line + "\n"
+				if limitBytes > 0 && written+int64(len(data)) > limitBytes {
+					data = data[:limitBytes-written]
+				}
+				n, werr := io.WriteString(writer, data)
+				written += int64(n)
+				if werr != nil {
+					klog.ErrorS(werr, "failed to write pod log", "SAEAPIServer", in.apiserver.Name, "path", in.path)
+					return false
+				}
+				if limitBytes > 0 && written >= limitBytes {
+					return false
+				}
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				klog.ErrorS(err, "failed to read pod log", "SAEAPIServer", in.apiserver.Name, "path", in.path)
+				return false
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+	if !write(response) {
+		return
+	}
+
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, key := range logQueryKeysToDrop {
+				query.Del(key)
+			}
+			since := start
+			if !cursor.last.IsZero() {
+				since = cursor.second
+			}
+			query.Set("sinceTime", since.UTC().Format(time.RFC3339))
+			logReq.URL.RawQuery = query.Encode()
+			if response, err = in.RoundTrip(logReq); err != nil {
+				klog.ErrorS(err, "failed to poll pod log", "SAEAPIServer", in.apiserver.Name, "path", in.path)
+				return
+			}
+			if response.StatusCode != http.StatusOK {
+				// the pod or container is gone, so the log ends
+				_ = response.Body.Close()
+				return
+			}
+			if !write(response) {
+				return
+			}
+		}
+	}
+}
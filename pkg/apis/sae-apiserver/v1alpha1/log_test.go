@@ -0,0 +1,175 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLogCursor(t *testing.T) {
+	cursor := newLogCursor()
+	var written []string
+	for _, poll := range [][]string{
+		{"2022-01-01T00:00:00.1Z a", "2022-01-01T00:00:01Z b", "2022-01-01T00:00:01Z b"},
+		// polled again since the second of the cursor, with the same line logged once more
+		{"2022-01-01T00:00:01Z b", "2022-01-01T00:00:01Z b", "2022-01-01T00:00:01Z b", "2022-01-01T00:00:01.5Z c"},
+		// lines before the last written one are never written again
+		{"2022-01-01T00:00:01Z b", "2022-01-01T00:00:02Z d", "no timestamp"},
+	} {
+		cursor.poll()
+		for _, line := range poll {
+			if cursor.accept(line) {
+				written = append(written, line)
+			}
+		}
+	}
+	expected := []string{
+		"2022-01-01T00:00:00.1Z a", "2022-01-01T00:00:01Z b", "2022-01-01T00:00:01Z b",
+		"2022-01-01T00:00:01Z b", "2022-01-01T00:00:01.5Z c",
+		"2022-01-01T00:00:02Z d", "no timestamp",
+	}
+	if !reflect.DeepEqual(written, expected) {
+		t.Fatalf("expected %v, got %v", expected, written)
+	}
+}
+
+// fakePodLog is the pod log growing at each poll, served by the fake SAE with
+// sinceTime in seconds as the kubelet does
+type fakePodLog struct {
+	mu     sync.Mutex
+	lines  []string
+	growth [][]string
+	polls  []url.Values
+}
+
+func (in *fakePodLog) respond(envelope *input) (int, []byte) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	u, _ := url.Parse(envelope.Path)
+	query := u.Query()
+	if len(in.polls) > 0 && len(in.polls) <= len(in.growth) {
+		in.lines = append(in.lines, in.growth[len(in.polls)-1]...)
+	}
+	in.polls = append(in.polls, query)
+	var since time.Time
+	if s := query.Get("sinceTime"); s != "" {
+		since, _ = time.Parse(time.RFC3339, s)
+	}
+	var body string
+	for _, line := range in.lines {
+		ts, _, _ := strings.Cut(line, " ")
+		if t, _ := time.Parse(time.RFC3339Nano, ts); !t.Before(since) {
+			body += line + "\n"
+		}
+	}
+	return http.StatusOK, []byte(body)
+}
+
+func (in *fakePodLog) pollCount() int {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return len(in.polls)
+}
+
+func TestServeFollowLog(t *testing.T) {
+	defer func(interval time.Duration) { logPollInterval = interval }(logPollInterval)
+	logPollInterval = 10 * time.Millisecond
+	fake := newFakeSAE(t)
+	defer fake.Close()
+	cases := map[string]struct {
+		query    string
+		expected string
+		// ends tells if the stream ends by itself, otherwise the client disconnects
+		ends bool
+	}{
+		"follow":      {query: "follow=true", expected: "start\ntick\ntick\ntick\ntock\ndone\n"},
+		"timestamps":  {query: "follow=true&timestamps=true", expected: "2022-01-01T00:00:00.1Z start\n2022-01-01T00:00:01Z tick\n2022-01-01T00:00:01Z tick\n2022-01-01T00:00:01Z tick\n2022-01-01T00:00:01.5Z tock\n2022-01-01T00:00:02Z done\n"},
+		"limit bytes": {query: "follow=true&limitBytes=10", expected: "start\ntick", ends: true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			podLog := &fakePodLog{
+				lines: []string{"2022-01-01T00:00:00.1Z start", "2022-01-01T00:00:01Z tick", "2022-01-01T00:00:01Z tick"},
+				growth: [][]string{
+					// the same line logged once more in the second polled again
+					{"2022-01-01T00:00:01Z tick", "2022-01-01T00:00:01.5Z tock"},
+					{},
+					{"2022-01-01T00:00:02Z done"},
+				},
+			}
+			fake.respond = podLog.respond
+			defer func() { fake.respond = nil }()
+			handler := fake.newHandler(t, nil)
+			handler.path = "/api/v1/namespaces/default/pods/web/log"
+			handler.responder = &errorResponder{}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			req := httptest.NewRequest(http.MethodGet, handler.path+"?"+c.query, nil).WithContext(ctx)
+			recorder := httptest.NewRecorder()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				handler.serveFollowLog(recorder, req)
+			}()
+			if !c.ends {
+				for deadline := time.Now().Add(5 * time.Second); podLog.pollCount() < len(podLog.growth)+3; {
+					if time.Now().After(deadline) {
+						t.Fatalf("expected the log to be polled, got %d polls", podLog.pollCount())
+					}
+					time.Sleep(logPollInterval)
+				}
+				// the client disconnects
+				cancel()
+			}
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected the follow to end")
+			}
+			polls := podLog.pollCount()
+			time.Sleep(5 * logPollInterval)
+			if podLog.pollCount() != polls {
+				t.Fatalf("expected no polls after the follow ends")
+			}
+			if recorder.Code != http.StatusOK || recorder.Body.String() != c.expected {
+				t.Fatalf("expected %q, got %d %q", c.expected, recorder.Code, recorder.Body.String())
+			}
+			first := podLog.polls[0]
+			if first.Get("timestamps") != "true" || first.Has("follow") || first.Has("sinceTime") {
+				t.Fatalf("unexpected query of the first request %v", first)
+			}
+			if c.ends {
+				return
+			}
+			// the polls overlap the second of the last written line
+			for i, expected := range []string{"2022-01-01T00:00:01Z", "2022-01-01T00:00:01Z", "2022-01-01T00:00:01Z", "2022-01-01T00:00:02Z"} {
+				if since := podLog.polls[i+1].Get("sinceTime"); since != expected {
+					t.Fatalf("expected the poll %d since %s, got %s", i+1, expected, since)
+				}
+			}
+		})
+	}
+}
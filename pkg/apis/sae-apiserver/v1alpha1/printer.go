@@ -19,56 +19,123 @@ package v1alpha1
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/kubevela/pkg/util/slices"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
 )
 
 func (in *SAEAPIServer) ConvertToTable(ctx context.Context, object runtime.Object, tableOptions runtime.Object) (*metav1.Table, error) {
+	return convertToTable(ctx, object, tableOptions)
+}
+
+func convertToTable(ctx context.Context, object runtime.Object, tableOptions runtime.Object) (*metav1.Table, error) {
+	var table *metav1.Table
 	switch o := object.(type) {
 	case tableConverter:
-		return o.ToTable(), nil
+		table = o.ToTable(ctx)
 	default:
 		return nil, fmt.Errorf("unsupported type for table conversion: %T", object)
 	}
+	if opt, ok := tableOptions.(*metav1.TableOptions); ok && opt.NoHeaders {
+		table.ColumnDefinitions = nil
+	}
+	return table, nil
 }
 
+const (
+	HealthReady           = "Ready"
+	HealthUnregistered    = "Unregistered"
+	HealthCredentialError = "CredentialError"
+)
+
 var (
 	definitions = []metav1.TableColumnDefinition{
 		{Name: "Name", Type: "string", Format: "name", Description: "the name of the SAEAPIServer"},
-		{Name: "Region", Type: "string", Description: "the region of the SAEAPIServer"},
-		{Name: "AK", Type: "string", Description: "the accessKeyId of the SAEAPIServer"},
+		{Name: "Region", Type: "string", Description: "the regions of the SAEAPIServer, led by the primary one"},
+		{Name: "Status", Type: "string", Description: "the health of the SAEAPIServer, whether it is registered in cluster-gateway and its credential can be resolved"},
+		{Name: "AK", Type: "string", Description: "the masked accessKeyId of the SAEAPIServer"},
+		{Name: "Age", Type: "string", Description: "the time since the SAEAPIServer is created"},
+		{Name: "Endpoint", Type: "string", Priority: 1, Description: "the proxy endpoint registered to cluster-gateway"},
+		{Name: "Credential-Type", Type: "string", Priority: 1, Description: "the credential type used by cluster-gateway to access the proxy"},
 	}
 )
 
-func (in *SAEAPIServer) row() *metav1.TableRow {
+func (in *SAEAPIServer) row(ctx context.Context) *metav1.TableRow {
 	return &metav1.TableRow{
 		Object: runtime.RawExtension{Object: in},
 		Cells: []interface{}{
 			in.Name,
-			in.Spec.Region,
-			in.Spec.AccessKeyId,
+			strings.Join(append([]string{in.Spec.Region}, in.Spec.Regions...), ","),
+			in.health(ctx),
+			in.maskedAccessKeyId(),
+			translateTimestampSince(in.CreationTimestamp),
+			in.Status.ProxyEndpoint,
+			in.Status.CredentialType,
 		},
 	}
 }
 
+// health tells if the SAEAPIServer is registered in cluster-gateway and its
+// credential resolves, i.e. the referred secret or SAECredential can be loaded.
+// The credential is not verified against alibaba-cloud, nor the OIDC role assumed.
+func (in *SAEAPIServer) health(ctx context.Context) string {
+	if in.Status.ProxyEndpoint == "" || in.Status.CredentialType == "" {
+		return HealthUnregistered
+	}
+	if _, err := in.resolveCredential(ctx); err != nil {
+		return HealthCredentialError
+	}
+	return HealthReady
+}
+
+// maskedAccessKeyId keeps the head and tail of the accessKeyId for telling
+// AKs apart, other credential sources are shown as their type
+func (in *SAEAPIServer) maskedAccessKeyId() string {
+	accessKeyId := in.Spec.AccessKeyId
+	if in.Spec.STS != nil {
+		accessKeyId = in.Spec.STS.AccessKeyId
+	}
+	if accessKeyId == "" {
+		return "<" + string(in.Spec.GetType()) + ">"
+	}
+	return maskAccessKeyId(accessKeyId)
+}
+
+func maskAccessKeyId(accessKeyId string) string {
+	if len(accessKeyId) <= 8 {
+		return strings.Repeat("*", len(accessKeyId))
+	}
+	return accessKeyId[:4] + strings.Repeat("*", len(accessKeyId)-8) + accessKeyId[len(accessKeyId)-4:]
+}
+
+func translateTimestampSince(timestamp metav1.Time) string {
+	if timestamp.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(timestamp.Time))
+}
+
 type tableConverter interface {
-	ToTable() *metav1.Table
+	ToTable(ctx context.Context) *metav1.Table
 }
 
-func (in *SAEAPIServer) ToTable() *metav1.Table {
+func (in *SAEAPIServer) ToTable(ctx context.Context) *metav1.Table {
 	return &metav1.Table{
 		ColumnDefinitions: definitions,
-		Rows:              []metav1.TableRow{*in.row()},
+		Rows:              []metav1.TableRow{*in.row(ctx)},
 	}
 }
 
-func (in *SAEAPIServerList) ToTable() *metav1.Table {
+func (in *SAEAPIServerList) ToTable(ctx context.Context) *metav1.Table {
 	return &metav1.Table{
+		ListMeta:          metav1.ListMeta{ResourceVersion: in.ResourceVersion},
 		ColumnDefinitions: definitions,
 		Rows: slices.Map(in.Items, func(item SAEAPIServer) metav1.TableRow {
-			return *item.row()
+			return *item.row(ctx)
 		}),
 	}
 }
@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kubevela/pkg/util/singleton"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMaskAccessKeyId(t *testing.T) {
+	cases := map[string]struct {
+		spec     SAEAPIServerSpec
+		expected string
+	}{
+		"inline":  {spec: SAEAPIServerSpec{SAEAPIServerCredential: SAEAPIServerCredential{AccessKeyId: "LTAI5tExampleAbcd", AccessKeySecret: "sk"}}, expected: "LTAI*********Abcd"},
+		"short":   {spec: SAEAPIServerSpec{SAEAPIServerCredential: SAEAPIServerCredential{AccessKeyId: "LTAI5t", AccessKeySecret: "sk"}}, expected: "******"},
+		"sts":     {spec: SAEAPIServerSpec{SAEAPIServerCredential: SAEAPIServerCredential{STS: &SAEAPIServerSTSCredential{AccessKeyId: "LTAI5tStsKeyWxyz", AccessKeySecret: "sk", RoleArn: "acs:ram::1:role/sae"}}}, expected: "LTAI********Wxyz"},
+		"secret":  {spec: SAEAPIServerSpec{SAEAPIServerCredential: SAEAPIServerCredential{SecretRef: &SAEAPIServerSecretReference{Name: "aksk"}}}, expected: "<SecretRef>"},
+		"ref":     {spec: SAEAPIServerSpec{SAEAPIServerCredential: SAEAPIServerCredential{CredentialRef: &SAECredentialReference{Name: "prod"}}}, expected: "<CredentialRef>"},
+		"missing": {expected: "<>"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			apiserver := &SAEAPIServer{Spec: c.spec}
+			if masked := apiserver.maskedAccessKeyId(); masked != c.expected {
+				t.Fatalf("expected %s, got %s", c.expected, masked)
+			}
+		})
+	}
+}
+
+func TestSAEAPIServerHealth(t *testing.T) {
+	singleton.StaticClient.Set(kubefake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: storageNamespace, Name: "aksk"},
+		Data:       map[string][]byte{IdentAccessKeyId: []byte("ak"), IdentAccessKeySecret: []byte("sk")},
+	}))
+	setFakeKubeClient()
+	registered := SAEAPIServerStatus{ProxyEndpoint: "https://proxy", CredentialType: "ServiceAccountToken"}
+	cases := map[string]struct {
+		credential SAEAPIServerCredential
+		status     SAEAPIServerStatus
+		expected   string
+	}{
+		"ready":            {credential: SAEAPIServerCredential{AccessKeyId: "ak", AccessKeySecret: "sk"}, status: registered, expected: HealthReady},
+		"secret":           {credential: SAEAPIServerCredential{SecretRef: &SAEAPIServerSecretReference{Name: "aksk"}}, status: registered, expected: HealthReady},
+		"unregistered":     {credential: SAEAPIServerCredential{AccessKeyId: "ak", AccessKeySecret: "sk"}, expected: HealthUnregistered},
+		"missing secret":   {credential: SAEAPIServerCredential{SecretRef: &SAEAPIServerSecretReference{Name: "missing"}}, status: registered, expected: HealthCredentialError},
+		"missing ref":      {credential: SAEAPIServerCredential{CredentialRef: &SAECredentialReference{Name: "missing"}}, status: registered, expected: HealthCredentialError},
+		"no credential":    {status: registered, expected: HealthCredentialError},
+		"unregistered ref": {credential: SAEAPIServerCredential{CredentialRef: &SAECredentialReference{Name: "missing"}}, expected: HealthUnregistered},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			apiserver := &SAEAPIServer{ObjectMeta: metav1.ObjectMeta{Name: "sae"}, Spec: SAEAPIServerSpec{SAEAPIServerCredential: c.credential}, Status: c.status}
+			if health := apiserver.health(context.Background()); health != c.expected {
+				t.Fatalf("expected %s, got %s", c.expected, health)
+			}
+		})
+	}
+}
+
+func TestConvertToTable(t *testing.T) {
+	list := &SAEAPIServerList{
+		ListMeta: metav1.ListMeta{ResourceVersion: "10"},
+		Items: []SAEAPIServer{{
+			ObjectMeta: metav1.ObjectMeta{Name: "sae", CreationTimestamp: metav1.Now()},
+			Spec: SAEAPIServerSpec{
+				SAEAPIServerCredential: SAEAPIServerCredential{AccessKeyId: "LTAI5tExampleAbcd", AccessKeySecret: "secret"},
+				Region:                 "cn-hangzhou",
+				Regions:                []string{"cn-shanghai"},
+			},
+			Status: SAEAPIServerStatus{ProxyEndpoint: "https://proxy", CredentialType: "ServiceAccountToken"},
+		}},
+	}
+	table, err := convertToTable(context.Background(), list, &metav1.TableOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.ResourceVersion != "10" || len(table.Rows) != 1 || len(table.ColumnDefinitions) != len(table.Rows[0].Cells) {
+		t.Fatalf("unexpected table %+v", table)
+	}
+	// kubectl prints the columns of priority 0 by default, and the others only with -o wide
+	expected := map[string]interface{}{
+		"Name": "sae", "Region": "cn-hangzhou,cn-shanghai", "Status": HealthReady, "AK": "LTAI*********Abcd", "Age": "0s",
+		"Endpoint": "https://proxy", "Credential-Type": "ServiceAccountToken",
+	}
+	wide := map[string]bool{"Endpoint": true, "Credential-Type": true}
+	for i, column := range table.ColumnDefinitions {
+		if (column.Priority != 0) != wide[column.Name] {
+			t.Fatalf("expected only the wide columns to have a priority, got %s %d", column.Name, column.Priority)
+		}
+		if cell := table.Rows[0].Cells[i]; cell != expected[column.Name] {
+			t.Fatalf("expected the %s cell to be %v, got %v", column.Name, expected[column.Name], cell)
+		}
+	}
+	for _, cell := range table.Rows[0].Cells {
+		if cell == "secret" {
+			t.Fatalf("expected the accessKeySecret not to be printed")
+		}
+	}
+
+	// the headers are left out with --no-headers
+	table, err = convertToTable(context.Background(), &list.Items[0], &metav1.TableOptions{NoHeaders: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table.ColumnDefinitions) != 0 || len(table.Rows) != 1 || table.Rows[0].Cells[0] != "sae" {
+		t.Fatalf("expected the row without headers, got %+v", table)
+	}
+
+	credential := &SAECredential{ObjectMeta: metav1.ObjectMeta{Name: "prod"}, Spec: SAECredentialSpec{AccessKeyId: "LTAI5tExampleAbcd", AccessKeySecret: "secret"}}
+	table, err = credential.ConvertToTable(context.Background(), credential, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table.ColumnDefinitions) != 3 || table.Rows[0].Cells[1] != "LTAI*********Abcd" {
+		t.Fatalf("expected the masked AK of the SAECredential, got %+v", table.Rows[0].Cells)
+	}
+}
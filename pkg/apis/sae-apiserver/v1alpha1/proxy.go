@@ -27,14 +27,19 @@ import (
 	"net/url"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/aliyun/alibaba-cloud-sdk-go/sdk"
 	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	registryrest "k8s.io/apiserver/pkg/registry/rest"
 	"k8s.io/apiserver/pkg/server"
+	"k8s.io/klog/v2"
 	"k8s.io/utils/strings/slices"
 	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
 	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource/resourcerest"
@@ -47,13 +52,15 @@ var _ resourcerest.Connecter = &SAEAPIServerProxy{}
 
 var proxyMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
 
+// +k8s:openapi-gen=false
 type SAEAPIServerProxy struct{}
 
-// SAEAPIServerProxyOptions
+// SAEAPIServerProxyOptions is the options of the proxy subresource
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type SAEAPIServerProxyOptions struct {
-	metav1.TypeMeta
+	metav1.TypeMeta `json:",inline"`
 
+	// Path is the path of the request to the SAE APIServer
 	Path string `json:"path"`
 }
 
@@ -99,17 +106,55 @@ func (in *SAEAPIServerProxy) Connect(ctx context.Context, id string, options run
 	}
 	apiserver := parentObj.(*SAEAPIServer)
 
-	cli, err := sdk.NewClientWithAccessKey(apiserver.Spec.Region, apiserver.Spec.AccessKeyId, apiserver.Spec.AccessKeySecret)
-	if err != nil {
-		return nil, fmt.Errorf("cannot create alibaba-cloud client with ak/sk: %w", err)
-	}
-
-	return &proxyHandler{
+	handler := &proxyHandler{
 		apiserver: apiserver,
 		path:      opts.Path,
 		responder: r,
-		cli:       cli,
-	}, nil
+		region:    apiserver.Spec.Region,
+		endpoint:  apiserver.Spec.Endpoint,
+	}
+	if region, reqPath, ok := parseRegionPath(opts.Path); ok {
+		if !apiserver.HasRegion(region) {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("region %s is not served by SAEAPIServer %s", region, id))
+		}
+		if region != apiserver.Spec.Region {
+			// the endpoint override only applies to the primary region
+			handler.endpoint = ""
+		}
+		handler.region, handler.path = region, reqPath
+	}
+	if errs := ValidateEndpoint(handler.endpoint, field.NewPath("spec", "endpoint")); len(errs) > 0 {
+		// stored before the endpoint was restricted
+		return nil, apierrors.NewForbidden(GroupVersion.WithResource(SAEAPIServerResource).GroupResource(), id, errs.ToAggregate())
+	}
+	if i := apiserver.matchIdentityMapping(ctx); i >= 0 {
+		handler.identity = strconv.Itoa(i)
+	}
+	if handler.cli, handler.release, err = apiserver.NewClient(ctx, handler.region); err != nil {
+		return nil, fmt.Errorf("cannot create alibaba-cloud client: %w", err)
+	}
+	return handler, nil
+}
+
+// cacheKeyPrefix keys the responses cached for the SAEAPIServer, which differ by
+// region and by the identity the caller is mapped to
+func (in *proxyHandler) cacheKeyPrefix() string {
+	return in.apiserver.Name + "/" + in.region + "/" + in.identity
+}
+
+// proxyRegionsPrefix routes the proxy requests to the additional regions,
+// such as proxy/regions/cn-shanghai/api/v1/namespaces
+const proxyRegionsPrefix = "regions"
+
+func parseRegionPath(p string) (region string, reqPath string, ok bool) {
+	parts := strings.SplitN(strings.TrimPrefix(p, "/"), "/", 3)
+	if len(parts) < 2 || parts[0] != proxyRegionsPrefix || parts[1] == "" {
+		return "", p, false
+	}
+	if len(parts) == 3 {
+		reqPath = parts[2]
+	}
+	return parts[1], "/" + reqPath, true
 }
 
 const (
@@ -120,37 +165,84 @@ const (
 	saeEndpointType = "openAPI"
 )
 
+// +k8s:openapi-gen=false
 type proxyHandler struct {
 	apiserver *SAEAPIServer
 	path      string
 	responder registryrest.Responder
+	region    string
+	endpoint  string
 	cli       *sdk.Client
+	// identity is the index of the identity mapping of the caller, empty for the
+	// credential of the SAEAPIServer
+	identity string
+	// release returns the client, which is not shared with other requests
+	release func()
 }
 
 func (in *proxyHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if in.release != nil {
+		defer in.release()
+	}
+	if err := in.checkSupportedResource(request); err != nil {
+		in.responder.Error(err)
+		return
+	}
+	if isStreamRequest(request) {
+		in.responder.Error(in.newStreamNotImplementedError(in.path))
+		return
+	}
+	if isWatchRequest(request) {
+		in.serveWatch(writer, request)
+		return
+	}
+	if isFollowLogRequest(request, in.path) {
+		in.serveFollowLog(writer, request)
+		return
+	}
+	if isDiscoveryRequest(request, in.path) {
+		in.serveDiscovery(writer, request)
+		return
+	}
 	response, err := in.RoundTrip(request)
 	if err == nil {
-		var body []byte
-		body, err = io.ReadAll(response.Body)
-		if err == nil {
-			for key, values := range response.Header {
-				for _, val := range values {
-					writer.Header().Add(key, val)
-				}
-			}
-			writer.WriteHeader(response.StatusCode)
-			_, err = writer.Write(body)
-		}
+		response = in.recordList(request, response)
+		response, err = encodeResponse(request, response)
 	}
 	if err != nil {
 		in.responder.Error(err)
+		return
+	}
+	defer func() { _ = response.Body.Close() }()
+	in.writeResponse(writer, request, response)
+}
+
+// writeResponse copies the response of SAE with the allowed headers to the
+// client, compressed by gzip if the client accepts it and the response is large
+func (in *proxyHandler) writeResponse(writer http.ResponseWriter, request *http.Request, response *http.Response) {
+	for key, values := range in.filterResponseHeader(response.Header) {
+		for _, val := range values {
+			writer.Header().Add(key, val)
+		}
+	}
+	var out io.Writer = writer
+	if acceptsGzip(request.Header) && response.Header.Get("Content-Encoding") == "" && shouldCompress(response.ContentLength) {
+		gw := newGzipResponseWriter(writer)
+		defer func() { _ = gw.Close() }()
+		out = gw
+	}
+	writer.WriteHeader(response.StatusCode)
+	if flusher, ok := writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	// the status is already sent, errors can only be logged from now on
+	buf := make([]byte, proxyBufferSize)
+	if _, err := io.CopyBuffer(out, response.Body, buf); err != nil {
+		klog.ErrorS(err, "failed to write proxy response", "SAEAPIServer", in.apiserver.Name, "path", in.path)
 	}
 }
 
 func (in *proxyHandler) RoundTrip(httpReq *http.Request) (*http.Response, error) {
-	req := requests.NewCommonRequest()
-	req.Scheme = requests.HTTPS
-	req.PathPattern = "/pop/v1/apiserver/proxy"
 	reqPath := strings.TrimPrefix(in.path, path.Join("/apis", Group, Version, SAEAPIServerResource, in.apiserver.Name, "proxy"))
 	if query := unescapeQueryValues(httpReq.URL.Query()); len(query) > 0 {
 		reqPath += "?" + query.Encode()
@@ -159,21 +251,32 @@ func (in *proxyHandler) RoundTrip(httpReq *http.Request) (*http.Response, error)
 		Path:        reqPath,
 		Method:      httpReq.Method,
 		ContentType: requests.Json,
-		Header:      httpReq.Header,
+		Header:      in.filterRequestHeader(httpReq.Header),
 	}
 	if httpReq.Body != nil {
 		data, _ := io.ReadAll(httpReq.Body)
-		body.Content = string(data)
-	}
-	req.SetContent(body.json())
-	req.Version = saeVersion
-	req.ApiName = saeAPIName
-	req.Product = saeProduct
-	req.ServiceCode = saeServiceCode
-	req.EndpointType = saeEndpointType
-	req.Method = requests.POST
-	req.SetContentType(requests.Json)
-	response, err := in.cli.ProcessCommonRequest(req)
+		data, err := decodeRequestContent(body.Header, data)
+		if err != nil {
+			return nil, err
+		}
+		body.setContent(data, in.apiserver.Spec.Proxy.GetRequestBodyEncoding(), in.apiserver.Spec.Proxy.compressesRequests())
+	}
+	content := body.json()
+	response, err := in.processRequest(httpReq.Context(), httpReq.Method, func() *requests.CommonRequest {
+		req := requests.NewCommonRequest()
+		req.Scheme = requests.HTTPS
+		req.PathPattern = "/pop/v1/apiserver/proxy"
+		req.Domain = in.endpoint
+		req.SetContent(content)
+		req.Version = saeVersion
+		req.ApiName = saeAPIName
+		req.Product = saeProduct
+		req.ServiceCode = saeServiceCode
+		req.EndpointType = saeEndpointType
+		req.Method = requests.POST
+		req.SetContentType(requests.Json)
+		return req
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -182,37 +285,123 @@ func (in *proxyHandler) RoundTrip(httpReq *http.Request) (*http.Response, error)
 	httpResponse.Proto = httpReq.Proto
 	httpResponse.ProtoMinor = httpReq.ProtoMinor
 	httpResponse.Request = httpReq
+	// the SDK reads the whole envelope into memory and cannot stream it, so its
+	// size is checked before it is decoded into further copies of the body
+	raw := response.GetHttpContentBytes()
+	if maxResponseSize > 0 && int64(len(raw)) > maxEnvelopeSize() {
+		return nil, apierrors.NewRequestEntityTooLargeError(fmt.Sprintf(
+			"the response envelope of SAEAPIServer %s is %d bytes, which exceeds the max response size %d bytes", in.apiserver.Name, len(raw), maxResponseSize))
+	}
 	out := &output{}
-	if err = json.Unmarshal(response.GetHttpContentBytes(), out); err != nil {
+	if err = json.Unmarshal(raw, out); err != nil {
 		return nil, err
 	}
 	httpResponse.Header = out.Header
 	httpResponse.StatusCode = out.Code
-	data, err := base64.StdEncoding.DecodeString(out.Body)
+	respBody, size, err := out.bodyReader()
 	if err != nil {
 		return nil, err
 	}
-	httpResponse.Body = io.NopCloser(bytes.NewReader(data))
-	httpResponse.ContentLength = int64(len(data))
+	if maxResponseSize > 0 && size > maxResponseSize {
+		return nil, apierrors.NewRequestEntityTooLargeError(fmt.Sprintf(
+			"the response of SAEAPIServer %s is %d bytes, which exceeds the max response size %d bytes", in.apiserver.Name, size, maxResponseSize))
+	}
+	httpResponse.Body = io.NopCloser(respBody)
+	httpResponse.ContentLength = size
+	if err = in.decodeContentEncoding(httpResponse); err != nil {
+		return nil, err
+	}
 	return httpResponse, nil
 }
 
+// +k8s:openapi-gen=false
 type input struct {
-	Path        string              `json:"path"`
-	Method      string              `json:"method"`
-	ContentType string              `json:"contentType"`
-	Content     string              `json:"content"`
-	Header      map[string][]string `json:"header,omitempty"`
+	Path        string `json:"path"`
+	Method      string `json:"method"`
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+	// ContentEncoding is not part of the envelope documented by the SAE
+	// VirtualServerProxy API, which takes the content as text. It is only set
+	// when the SAEAPIServer opts in to the base64 or gzip encodings, relying on
+	// the SAE endpoint to decode them, and is omitted otherwise.
+	ContentEncoding string              `json:"contentEncoding,omitempty"`
+	Header          map[string][]string `json:"header,omitempty"`
+}
+
+const (
+	// contentEncodingBase64 marks the content of the envelope as base64 encoded, so
+	// that binary bodies are not mangled as text
+	contentEncodingBase64 = "base64"
+	// contentEncodingGzip marks the content of the envelope as the base64 encoded
+	// body compressed by gzip
+	contentEncodingGzip = "gzip"
+)
+
+// setContent puts the body into the envelope as text, in base64 if the encoding
+// asks for it, or if the body is not valid UTF-8 under the Auto encoding. Large
+// bodies are compressed if asked.
+func (in *input) setContent(data []byte, encoding BodyEncoding, compress bool) {
+	if compress && shouldCompress(int64(len(data))) {
+		if compressed, err := gzipBytes(data); err == nil {
+			in.Content = base64.StdEncoding.EncodeToString(compressed)
+			in.ContentEncoding = contentEncodingGzip
+			return
+		}
+	}
+	if encoding == BodyEncodingBase64 || (encoding == BodyEncodingAuto && !utf8.Valid(data)) {
+		in.Content = base64.StdEncoding.EncodeToString(data)
+		in.ContentEncoding = contentEncodingBase64
+		return
+	}
+	in.Content = string(data)
 }
 
+// +k8s:openapi-gen=false
 type output struct {
 	RequestId string              `json:"requestId"`
 	Code      int                 `json:"code"`
 	Error     string              `json:"error,omitempty"`
-	Body      string              `json:"body,omitempty"`
+	Body      json.RawMessage     `json:"body,omitempty"`
 	Header    map[string][]string `json:"header,omitempty"`
 }
 
+// envelopeOverhead is the room for the fields of the envelope other than the body,
+// such as the headers
+const envelopeOverhead = 64 << 10
+
+// maxEnvelopeSize is the size of the envelope carrying the body of the max
+// response size, which is base64 encoded
+func maxEnvelopeSize() int64 {
+	return int64(base64.StdEncoding.EncodedLen(int(maxResponseSize))) + envelopeOverhead
+}
+
+// proxyBufferSize bounds the buffer for copying the response to the client
+const proxyBufferSize = 32 * 1024
+
+// bodyReader decodes the base64 body as a stream instead of decoding it into
+// memory at once, and returns the decoded size
+func (in *output) bodyReader() (io.Reader, int64, error) {
+	raw := []byte(in.Body)
+	if len(raw) == 0 || string(raw) == "null" {
+		return http.NoBody, 0, nil
+	}
+	if len(raw) < 2 || raw[0] != '"' || raw[len(raw)-1] != '"' {
+		return nil, 0, fmt.Errorf("invalid body in the response of SAE APIServer")
+	}
+	if bytes.IndexByte(raw, '\\') >= 0 {
+		// base64 never needs escaping, but the encoder may still escape '/'
+		var body string
+		if err := json.Unmarshal(raw, &body); err != nil {
+			return nil, 0, err
+		}
+		raw = []byte(body)
+	} else {
+		raw = raw[1 : len(raw)-1]
+	}
+	size := base64.StdEncoding.DecodedLen(len(raw)) - (len(raw) - len(bytes.TrimRight(raw, "=")))
+	return base64.NewDecoder(base64.StdEncoding, bytes.NewReader(raw)), int64(size), nil
+}
+
 func (in *input) json() []byte {
 	bt, _ := json.Marshal(in)
 	return bt
@@ -222,6 +411,7 @@ func NewProxyRequestEscaper(delegate http.Handler) http.Handler {
 	return &proxyRequestEscaper{delegate: delegate}
 }
 
+// +k8s:openapi-gen=false
 type proxyRequestEscaper struct {
 	delegate http.Handler
 }
@@ -0,0 +1,402 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/auth/credentials"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	contextutil "sigs.k8s.io/apiserver-runtime/pkg/util/context"
+)
+
+// fakeSAE is the SAE VirtualServerProxy echoing the request content. Like the
+// documented API, it takes the content as text and knows nothing of the
+// contentEncoding field, which the tests of the opt-in encodings decode by
+// themselves.
+type fakeSAE struct {
+	*httptest.Server
+	received []byte
+	envelope *input
+	// raw is the envelope as sent by the proxy
+	raw []byte
+	// response replaces the echo if set
+	response []byte
+	// respond answers the envelope with the status and body of the upstream if set
+	respond func(envelope *input) (int, []byte)
+	// throttled is the number of the next requests throttled by SAE
+	throttled int
+	// requests is the number of the requests received
+	requests int
+}
+
+func newFakeSAE(t *testing.T) *fakeSAE {
+	fake := &fakeSAE{}
+	fake.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.envelope = &input{}
+		fake.raw, _ = io.ReadAll(r.Body)
+		if err := json.Unmarshal(fake.raw, fake.envelope); err != nil {
+			t.Errorf("invalid envelope: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fake.received = []byte(fake.envelope.Content)
+		fake.requests++
+		w.Header().Set("Content-Type", "application/json")
+		if fake.throttled > 0 {
+			fake.throttled--
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"Code":"Throttling.User","Message":"Request was denied due to user flow control."}`))
+			return
+		}
+		if fake.response != nil {
+			_, _ = w.Write(fake.response)
+			return
+		}
+		if fake.respond != nil {
+			code, body := fake.respond(fake.envelope)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"requestId": "fake",
+				"code":      code,
+				"body":      base64.StdEncoding.EncodeToString(body),
+				"header":    map[string][]string{"Content-Type": {"text/plain"}},
+			})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"requestId": "fake",
+			"code":      http.StatusOK,
+			"body":      base64.StdEncoding.EncodeToString(fake.received),
+			"header":    map[string][]string{"Content-Type": {"application/octet-stream"}},
+		})
+	}))
+	return fake
+}
+
+// decodeContent decodes the content of the envelope by the opt-in encoding
+func decodeContent(t *testing.T, envelope *input) []byte {
+	switch envelope.ContentEncoding {
+	case "":
+		return []byte(envelope.Content)
+	case contentEncodingBase64:
+		data, err := base64.StdEncoding.DecodeString(envelope.Content)
+		if err != nil {
+			t.Errorf("invalid base64 content: %v", err)
+		}
+		return data
+	case contentEncodingGzip:
+		data, err := base64.StdEncoding.DecodeString(envelope.Content)
+		if err != nil {
+			t.Errorf("invalid base64 content: %v", err)
+		}
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("invalid gzip content: %v", err)
+		}
+		if data, err = io.ReadAll(reader); err != nil {
+			t.Errorf("invalid gzip content: %v", err)
+		}
+		return data
+	default:
+		t.Errorf("unknown content encoding %s", envelope.ContentEncoding)
+		return nil
+	}
+}
+
+// newHandler creates the proxy handler sending the requests to the fake SAE
+func (in *fakeSAE) newHandler(t *testing.T, config *SAEAPIServerProxyConfig) *proxyHandler {
+	cli, err := newSDKClient("cn-hangzhou", in.Client().Transport, credentials.NewAccessKeyCredential("ak", "sk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiserver := &SAEAPIServer{}
+	apiserver.Name, apiserver.Spec.Proxy = "sae", config
+	return &proxyHandler{
+		apiserver: apiserver,
+		path:      "/api/v1/namespaces/default/configmaps",
+		region:    "cn-hangzhou",
+		endpoint:  strings.TrimPrefix(in.URL, "https://"),
+		cli:       cli,
+	}
+}
+
+func TestRoundTripDefaultEnvelope(t *testing.T) {
+	fake := newFakeSAE(t)
+	defer fake.Close()
+	body := []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"text"},"data":{"text":"héllo wörld"}}`)
+	large := []byte(`{"data":{"text":"` + strings.Repeat("a", int(compressionThreshold)) + `"}}`)
+	for name, config := range map[string]*SAEAPIServerProxyConfig{
+		"nil":              nil,
+		"empty":            {},
+		"text":             {RequestBodyEncoding: BodyEncodingText},
+		"text compression": {RequestBodyEncoding: BodyEncodingText, CompressRequests: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler := fake.newHandler(t, config)
+			for _, b := range [][]byte{body, large} {
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/namespaces/default/configmaps", bytes.NewReader(b))
+				req.Header.Set("Content-Type", "application/json")
+				response, err := handler.RoundTrip(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// the envelope has only the fields documented by SAE, with the body as text
+				fields := map[string]json.RawMessage{}
+				if err = json.Unmarshal(fake.raw, &fields); err != nil {
+					t.Fatal(err)
+				}
+				for key := range fields {
+					if key != "path" && key != "method" && key != "contentType" && key != "content" && key != "header" {
+						t.Fatalf("unexpected field %s in the envelope %s", key, fake.raw)
+					}
+				}
+				if !bytes.Equal(fake.received, b) {
+					t.Fatalf("expected SAE to receive the body as text, got %q", fake.received)
+				}
+				if echoed, _ := io.ReadAll(response.Body); !bytes.Equal(echoed, b) {
+					t.Fatalf("expected the response %q, got %q", b, echoed)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundTripBodyEncoding(t *testing.T) {
+	binary := []byte{0xff, 0xfe, 0x00, 0x80, 'c', 'm', 0xc3, 0x28}
+	configMap, _ := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "binary", "namespace": "default"},
+		"data":       map[string]string{"text": "héllo wörld"},
+		"binaryData": map[string][]byte{"blob": binary},
+	})
+	bodies := map[string]struct {
+		contentType string
+		body        []byte
+	}{
+		"configmap":  {contentType: "application/json", body: configMap},
+		"binaryData": {contentType: "application/octet-stream", body: binary},
+		"empty":      {contentType: "application/json", body: []byte{}},
+	}
+	cases := map[string]struct {
+		encoding BodyEncoding
+		// expected content encoding of the text and the binary bodies
+		text, binary string
+	}{
+		"Auto":   {encoding: BodyEncodingAuto, text: "", binary: contentEncodingBase64},
+		"Text":   {encoding: BodyEncodingText, text: "", binary: ""},
+		"Base64": {encoding: BodyEncodingBase64, text: contentEncodingBase64, binary: contentEncodingBase64},
+	}
+	fake := newFakeSAE(t)
+	defer fake.Close()
+	for name, c := range cases {
+		for bodyName, b := range bodies {
+			t.Run(name+"/"+bodyName, func(t *testing.T) {
+				handler := fake.newHandler(t, &SAEAPIServerProxyConfig{RequestBodyEncoding: c.encoding})
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/namespaces/default/configmaps", bytes.NewReader(b.body))
+				req.Header.Set("Content-Type", b.contentType)
+				if _, err := handler.RoundTrip(req); err != nil {
+					t.Fatal(err)
+				}
+				expected := c.text
+				if bodyName == "binaryData" {
+					expected = c.binary
+				}
+				if fake.envelope.ContentEncoding != expected {
+					t.Fatalf("expected content encoding %q, got %q", expected, fake.envelope.ContentEncoding)
+				}
+				if c.encoding == BodyEncodingText && bodyName == "binaryData" {
+					// the invalid UTF-8 is not kept by the text content, which is why Auto exists
+					return
+				}
+				if decoded := decodeContent(t, fake.envelope); !bytes.Equal(decoded, b.body) {
+					t.Fatalf("expected the content to decode to %q, got %q", b.body, decoded)
+				}
+			})
+		}
+	}
+}
+
+func TestRoundTripCompressedBody(t *testing.T) {
+	fake := newFakeSAE(t)
+	defer fake.Close()
+	body := bytes.Repeat([]byte{0xff, 0x00, 'a'}, int(compressionThreshold))
+	for _, compress := range []bool{false, true} {
+		handler := fake.newHandler(t, &SAEAPIServerProxyConfig{RequestBodyEncoding: BodyEncodingAuto, CompressRequests: compress})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/namespaces/default/configmaps", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		if _, err := handler.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+		if compressed := fake.envelope.ContentEncoding == contentEncodingGzip; compressed != compress {
+			t.Fatalf("expected compressed %t, got content encoding %q", compress, fake.envelope.ContentEncoding)
+		}
+		if !bytes.Equal(decodeContent(t, fake.envelope), body) {
+			t.Fatalf("expected the content to decode to the original body")
+		}
+	}
+}
+
+func TestSetContentCompression(t *testing.T) {
+	large := bytes.Repeat([]byte("a"), int(compressionThreshold)+1)
+	cases := map[string]struct {
+		encoding BodyEncoding
+		compress bool
+		expected string
+	}{
+		"text":            {encoding: BodyEncodingText, expected: ""},
+		"auto":            {encoding: BodyEncodingAuto, expected: ""},
+		"base64":          {encoding: BodyEncodingBase64, expected: contentEncodingBase64},
+		"compress":        {encoding: BodyEncodingAuto, compress: true, expected: contentEncodingGzip},
+		"compress base64": {encoding: BodyEncodingBase64, compress: true, expected: contentEncodingGzip},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			body := &input{}
+			body.setContent(large, c.encoding, c.compress)
+			if body.ContentEncoding != c.expected {
+				t.Fatalf("expected content encoding %q, got %q", c.expected, body.ContentEncoding)
+			}
+		})
+	}
+}
+
+func TestRoundTripMaxResponseSize(t *testing.T) {
+	defer func(size int64) { maxResponseSize = size }(maxResponseSize)
+	maxResponseSize = 1 << 10
+	fake := newFakeSAE(t)
+	defer fake.Close()
+	handler := fake.newHandler(t, nil)
+	envelope := func(size int) []byte {
+		raw, _ := json.Marshal(map[string]interface{}{"code": http.StatusOK, "body": bytes.Repeat([]byte("a"), size)})
+		return raw
+	}
+	cases := map[string]struct {
+		response []byte
+		tooLarge bool
+	}{
+		"within":             {response: envelope(int(maxResponseSize))},
+		"body too large":     {response: envelope(int(maxResponseSize) + 1), tooLarge: true},
+		"envelope too large": {response: append([]byte(`{"code":200,"body":"`), bytes.Repeat([]byte("a"), int(maxEnvelopeSize()))...), tooLarge: true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			fake.response = c.response
+			_, err := handler.RoundTrip(httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/configmaps", nil))
+			if tooLarge := apierrors.IsRequestEntityTooLargeError(err); tooLarge != c.tooLarge {
+				t.Fatalf("expected too large %t, got %v", c.tooLarge, err)
+			}
+			if !c.tooLarge && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestParseRegionPath(t *testing.T) {
+	cases := map[string]struct {
+		path, region, reqPath string
+		ok                    bool
+	}{
+		"region":       {path: "/regions/cn-shanghai/api/v1/namespaces", region: "cn-shanghai", reqPath: "/api/v1/namespaces", ok: true},
+		"no slash":     {path: "regions/cn-shanghai/apis/apps/v1", region: "cn-shanghai", reqPath: "/apis/apps/v1", ok: true},
+		"region root":  {path: "/regions/cn-shanghai", region: "cn-shanghai", reqPath: "/", ok: true},
+		"no region":    {path: "/regions/", reqPath: "/regions/"},
+		"plain":        {path: "/api/v1/namespaces", reqPath: "/api/v1/namespaces"},
+		"regions kind": {path: "/apis/example.com/v1/regions/cn-shanghai", reqPath: "/apis/example.com/v1/regions/cn-shanghai"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			region, reqPath, ok := parseRegionPath(c.path)
+			if region != c.region || reqPath != c.reqPath || ok != c.ok {
+				t.Fatalf("expected %q %q %t, got %q %q %t", c.region, c.reqPath, c.ok, region, reqPath, ok)
+			}
+		})
+	}
+}
+
+// fakeParentStorage serves the SAEAPIServer to the proxy subresource
+type fakeParentStorage struct {
+	apiserver *SAEAPIServer
+}
+
+func (in *fakeParentStorage) New() runtime.Object { return &SAEAPIServer{} }
+
+func (in *fakeParentStorage) Destroy() {}
+
+func (in *fakeParentStorage) Get(_ context.Context, name string, _ *metav1.GetOptions) (runtime.Object, error) {
+	if name != in.apiserver.Name {
+		return nil, apierrors.NewNotFound(GroupVersion.WithResource(SAEAPIServerResource).GroupResource(), name)
+	}
+	return in.apiserver.DeepCopy(), nil
+}
+
+func TestConnectRegion(t *testing.T) {
+	defer func(clients *clientCache) { proxyClients = clients }(proxyClients)
+	proxyClients = &clientCache{entries: map[string]*clientCacheEntry{}}
+	apiserver := &SAEAPIServer{ObjectMeta: metav1.ObjectMeta{Name: "sae"}}
+	apiserver.Spec.Region, apiserver.Spec.Regions = "cn-hangzhou", []string{"cn-shanghai"}
+	apiserver.Spec.Endpoint = "sae-vpc.cn-hangzhou.aliyuncs.com"
+	apiserver.Spec.AccessKeyId, apiserver.Spec.AccessKeySecret = "ak", "sk"
+	ctx := contextutil.WithParentStorage(context.Background(), &fakeParentStorage{apiserver: apiserver})
+	cases := map[string]struct {
+		path, region, reqPath, endpoint string
+		badRequest                      bool
+	}{
+		"primary":        {path: "/api/v1/pods", region: "cn-hangzhou", reqPath: "/api/v1/pods", endpoint: "sae-vpc.cn-hangzhou.aliyuncs.com"},
+		"primary region": {path: "/regions/cn-hangzhou/api/v1/pods", region: "cn-hangzhou", reqPath: "/api/v1/pods", endpoint: "sae-vpc.cn-hangzhou.aliyuncs.com"},
+		// the endpoint override of the primary region is not used in the others
+		"additional": {path: "/regions/cn-shanghai/api/v1/pods", region: "cn-shanghai", reqPath: "/api/v1/pods"},
+		"unknown":    {path: "/regions/us-west-1/api/v1/pods", badRequest: true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h, err := (&SAEAPIServerProxy{}).Connect(ctx, "sae", &SAEAPIServerProxyOptions{Path: c.path}, &errorResponder{})
+			if c.badRequest {
+				if !apierrors.IsBadRequest(err) {
+					t.Fatalf("expected 400, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			handler := h.(*proxyHandler)
+			defer handler.release()
+			if handler.region != c.region || handler.path != c.reqPath || handler.endpoint != c.endpoint {
+				t.Fatalf("expected region %s, path %s and endpoint %q, got %s, %s and %q", c.region, c.reqPath, c.endpoint, handler.region, handler.path, handler.endpoint)
+			}
+		})
+	}
+	// the clients are cached once per region, however many requests are served
+	if keys := sets.StringKeySet(proxyClients.entries); !keys.Equal(sets.NewString(clientCacheKey("sae", "cn-hangzhou"), clientCacheKey("sae", "cn-shanghai"))) {
+		t.Fatalf("expected a client per region, got %v", keys.List())
+	}
+}
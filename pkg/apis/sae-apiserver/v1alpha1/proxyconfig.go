@@ -0,0 +1,67 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// BodyEncoding is how the body is put into the SAE envelope
+type BodyEncoding string
+
+const (
+	BodyEncodingAuto   BodyEncoding = "Auto"
+	BodyEncodingText   BodyEncoding = "Text"
+	BodyEncodingBase64 BodyEncoding = "Base64"
+)
+
+var bodyEncodings = []string{string(BodyEncodingText), string(BodyEncodingAuto), string(BodyEncodingBase64)}
+
+// Validate checks the proxy config, which is optional
+func (in *SAEAPIServerProxyConfig) Validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if in == nil {
+		return errs
+	}
+	switch in.RequestBodyEncoding {
+	case "", BodyEncodingAuto, BodyEncodingText, BodyEncodingBase64:
+	default:
+		errs = append(errs, field.NotSupported(fldPath.Child("requestBodyEncoding"), in.RequestBodyEncoding, bodyEncodings))
+	}
+	errs = append(errs, in.Retry.Validate(fldPath.Child("retry"))...)
+	errs = append(errs, in.RateLimit.Validate(fldPath.Child("rateLimit"))...)
+	errs = append(errs, in.Headers.Validate(fldPath.Child("headers"))...)
+	for i, r := range in.SupportedResources {
+		errs = append(errs, r.Validate(fldPath.Child("supportedResources").Index(i))...)
+	}
+	return errs
+}
+
+// GetRequestBodyEncoding returns the request body encoding, defaults to Text,
+// so that the envelope is the one documented by SAE unless asked otherwise
+func (in *SAEAPIServerProxyConfig) GetRequestBodyEncoding() BodyEncoding {
+	if in == nil || in.RequestBodyEncoding == "" {
+		return BodyEncodingText
+	}
+	return in.RequestBodyEncoding
+}
+
+// compressesRequests tells if the large request bodies are sent compressed,
+// which is off by default as SAE does not document the gzip content encoding
+func (in *SAEAPIServerProxyConfig) compressesRequests() bool {
+	return in != nil && in.CompressRequests && in.RequestBodyEncoding != BodyEncodingText
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const (
+	// rateLimiterIdleTimeout is how long an unused token bucket is kept
+	rateLimiterIdleTimeout = 10 * time.Minute
+	// rateLimiterSweepSize is the number of token buckets from which the idle ones are swept
+	rateLimiterSweepSize = 1024
+)
+
+// rateLimitPolicy is the effective rate limit, with the flags as defaults
+// +k8s:openapi-gen=false
+type rateLimitPolicy struct {
+	qps          int
+	burst        int
+	perUser      bool
+	queueTimeout time.Duration
+}
+
+func (in *SAEAPIServerProxyConfig) getRateLimitPolicy() rateLimitPolicy {
+	policy := rateLimitPolicy{qps: rateLimitQPS, burst: rateLimitBurst, perUser: rateLimitPerUser, queueTimeout: rateLimitQueueTimeout}
+	if in == nil || in.RateLimit == nil {
+		return policy
+	}
+	if in.RateLimit.QPS != nil {
+		policy.qps = int(*in.RateLimit.QPS)
+	}
+	if in.RateLimit.Burst != nil {
+		policy.burst = int(*in.RateLimit.Burst)
+	}
+	if in.RateLimit.PerUser != nil {
+		policy.perUser = *in.RateLimit.PerUser
+	}
+	if in.RateLimit.QueueTimeout != nil {
+		policy.queueTimeout = in.RateLimit.QueueTimeout.Duration
+	}
+	if policy.burst < 1 {
+		policy.burst = 1
+	}
+	return policy
+}
+
+// Validate checks the rate limit
+func (in *SAEAPIServerRateLimit) Validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if in == nil {
+		return errs
+	}
+	if in.QPS != nil && *in.QPS < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("qps"), *in.QPS, "must not be negative"))
+	}
+	if in.Burst != nil && *in.Burst < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("burst"), *in.Burst, "must not be negative"))
+	}
+	if in.QueueTimeout != nil && in.QueueTimeout.Duration < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("queueTimeout"), in.QueueTimeout.Duration.String(), "must not be negative"))
+	}
+	return errs
+}
+
+// rateLimiters are the token buckets keyed by SAEAPIServer, and by caller as well
+// when limited per user. The limits are updated in place when the spec changes.
+// +k8s:openapi-gen=false
+type rateLimiters struct {
+	mu       sync.Mutex
+	limiters map[string]*rateLimiterEntry
+}
+
+// +k8s:openapi-gen=false
+type rateLimiterEntry struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+var proxyRateLimiters = &rateLimiters{limiters: map[string]*rateLimiterEntry{}}
+
+func (in *rateLimiters) get(key string, policy rateLimitPolicy) *rate.Limiter {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	now := time.Now()
+	if len(in.limiters) >= rateLimiterSweepSize {
+		for k, entry := range in.limiters {
+			if now.Sub(entry.lastUsed) > rateLimiterIdleTimeout {
+				delete(in.limiters, k)
+			}
+		}
+	}
+	entry, found := in.limiters[key]
+	if !found {
+		entry = &rateLimiterEntry{limiter: rate.NewLimiter(rate.Limit(policy.qps), policy.burst)}
+		in.limiters[key] = entry
+	}
+	if entry.limiter.Limit() != rate.Limit(policy.qps) {
+		entry.limiter.SetLimitAt(now, rate.Limit(policy.qps))
+	}
+	if entry.limiter.Burst() != policy.burst {
+		entry.limiter.SetBurstAt(now, policy.burst)
+	}
+	entry.lastUsed = now
+	return entry.limiter
+}
+
+// acquireToken takes a token from the buckets of the SAEAPIServer and the caller
+// before calling SAE. Over-limit requests wait for the token up to the queue
+// timeout, and are rejected with 429 and Retry-After if it takes longer.
+func (in *proxyHandler) acquireToken(ctx context.Context) error {
+	policy := in.apiserver.Spec.Proxy.getRateLimitPolicy()
+	if policy.qps <= 0 {
+		return nil
+	}
+	keys := []string{in.apiserver.Name}
+	if user, ok := request.UserFrom(ctx); ok && policy.perUser {
+		keys = append(keys, in.apiserver.Name+"/"+user.GetName())
+	}
+	now := time.Now()
+	var reservations []*rate.Reservation
+	cancel := func() {
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+	}
+	var delay time.Duration
+	for _, key := range keys {
+		r := proxyRateLimiters.get(key, policy).ReserveN(now, 1)
+		reservations = append(reservations, r)
+		if !r.OK() {
+			cancel()
+			return apierrors.NewTooManyRequests(fmt.Sprintf("rate limited by SAEAPIServer %s", in.apiserver.Name), 1)
+		}
+		if d := r.DelayFrom(now); d > delay {
+			delay = d
+		}
+	}
+	if delay == 0 {
+		return nil
+	}
+	if delay > policy.queueTimeout {
+		cancel()
+		return apierrors.NewTooManyRequests(fmt.Sprintf("rate limited by SAEAPIServer %s at %d qps", in.apiserver.Name, policy.qps),
+			int((delay+time.Second-1)/time.Second))
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		cancel()
+		return apierrors.NewTooManyRequests(fmt.Sprintf("rate limited by SAEAPIServer %s: %v", in.apiserver.Name, ctx.Err()), 1)
+	case <-timer.C:
+		return nil
+	}
+}
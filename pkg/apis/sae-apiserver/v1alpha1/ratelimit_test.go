@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/utils/pointer"
+)
+
+// newRateLimitedHandler creates the handler of the SAEAPIServer with the rate
+// limit, with the token buckets reset
+func newRateLimitedHandler(t *testing.T, rateLimit *SAEAPIServerRateLimit) *proxyHandler {
+	prev := proxyRateLimiters
+	proxyRateLimiters = &rateLimiters{limiters: map[string]*rateLimiterEntry{}}
+	t.Cleanup(func() { proxyRateLimiters = prev })
+	apiserver := &SAEAPIServer{ObjectMeta: metav1.ObjectMeta{Name: "sae"}}
+	apiserver.Spec.Proxy = &SAEAPIServerProxyConfig{RateLimit: rateLimit}
+	return &proxyHandler{apiserver: apiserver}
+}
+
+func TestAcquireTokenUnlimited(t *testing.T) {
+	handler := newRateLimitedHandler(t, &SAEAPIServerRateLimit{QPS: pointer.Int32(0)})
+	for i := 0; i < 100; i++ {
+		if err := handler.acquireToken(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(proxyRateLimiters.limiters) != 0 {
+		t.Fatalf("expected no token buckets, got %d", len(proxyRateLimiters.limiters))
+	}
+}
+
+func TestAcquireTokenQueues(t *testing.T) {
+	handler := newRateLimitedHandler(t, &SAEAPIServerRateLimit{QPS: pointer.Int32(20), Burst: pointer.Int32(1), QueueTimeout: &metav1.Duration{Duration: time.Second}})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := handler.acquireToken(context.Background()); err != nil {
+			t.Fatalf("expected the request %d to be queued, got %v", i, err)
+		}
+	}
+	// the burst is taken at once, the others wait 50ms each
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("expected the requests to wait for the tokens, took %s", elapsed)
+	}
+}
+
+func TestAcquireTokenTooManyRequests(t *testing.T) {
+	handler := newRateLimitedHandler(t, &SAEAPIServerRateLimit{QPS: pointer.Int32(1), Burst: pointer.Int32(1), QueueTimeout: &metav1.Duration{Duration: 10 * time.Millisecond}})
+	if err := handler.acquireToken(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := handler.acquireToken(context.Background())
+	if !apierrors.IsTooManyRequests(err) {
+		t.Fatalf("expected 429, got %v", err)
+	}
+	if seconds, ok := apierrors.SuggestsClientDelay(err); !ok || seconds != 1 {
+		t.Fatalf("expected Retry-After 1s, got %d", seconds)
+	}
+
+	// the client going away while queued gives up the token
+	handler = newRateLimitedHandler(t, &SAEAPIServerRateLimit{QPS: pointer.Int32(1), Burst: pointer.Int32(1), QueueTimeout: &metav1.Duration{Duration: 5 * time.Second}})
+	_ = handler.acquireToken(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err = handler.acquireToken(ctx); !apierrors.IsTooManyRequests(err) {
+		t.Fatalf("expected 429 once the client is gone, got %v", err)
+	}
+}
+
+func TestAcquireTokenPerUser(t *testing.T) {
+	withUser := func(name string) context.Context {
+		return request.WithUser(context.Background(), &user.DefaultInfo{Name: name})
+	}
+	cases := map[string]struct {
+		perUser  bool
+		contexts []context.Context
+		expected []string
+	}{
+		"shared":    {contexts: []context.Context{withUser("alice"), withUser("bob")}, expected: []string{"sae"}},
+		"per user":  {perUser: true, contexts: []context.Context{withUser("alice"), withUser("bob"), withUser("alice")}, expected: []string{"sae", "sae/alice", "sae/bob"}},
+		"anonymous": {perUser: true, contexts: []context.Context{context.Background()}, expected: []string{"sae"}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			handler := newRateLimitedHandler(t, &SAEAPIServerRateLimit{QPS: pointer.Int32(100), PerUser: pointer.Bool(c.perUser)})
+			for _, ctx := range c.contexts {
+				if err := handler.acquireToken(ctx); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if keys := sets.StringKeySet(proxyRateLimiters.limiters); !keys.Equal(sets.NewString(c.expected...)) {
+				t.Fatalf("expected the token buckets %v, got %v", c.expected, keys.List())
+			}
+		})
+	}
+
+	// the caller over its own limit is rejected, while the bucket of the SAEAPIServer
+	// still has tokens for the others
+	handler := newRateLimitedHandler(t, &SAEAPIServerRateLimit{QPS: pointer.Int32(1), Burst: pointer.Int32(2), PerUser: pointer.Bool(true), QueueTimeout: &metav1.Duration{}})
+	proxyRateLimiters.get("sae/alice", handler.apiserver.Spec.Proxy.getRateLimitPolicy()).AllowN(time.Now(), 2)
+	if err := handler.acquireToken(withUser("alice")); !apierrors.IsTooManyRequests(err) {
+		t.Fatalf("expected alice to be rate limited, got %v", err)
+	}
+	for _, name := range []string{"bob", "carol"} {
+		if err := handler.acquireToken(withUser(name)); err != nil {
+			t.Fatalf("expected %s not to be limited by alice, got %v", name, err)
+		}
+	}
+}
+
+func TestProcessRequestRateLimitsRetries(t *testing.T) {
+	fake := newFakeSAE(t)
+	defer fake.Close()
+	config := &SAEAPIServerProxyConfig{
+		Retry: &SAEAPIServerRetryPolicy{
+			MaxRetries:     pointer.Int32(2),
+			InitialBackoff: &metav1.Duration{Duration: time.Millisecond},
+			MaxBackoff:     &metav1.Duration{Duration: time.Second},
+		},
+		RateLimit: &SAEAPIServerRateLimit{QPS: pointer.Int32(1), Burst: pointer.Int32(2), QueueTimeout: &metav1.Duration{}},
+	}
+	prev := proxyRateLimiters
+	proxyRateLimiters = &rateLimiters{limiters: map[string]*rateLimiterEntry{}}
+	defer func() { proxyRateLimiters = prev }()
+	fake.throttled = 2
+	defer func() { fake.throttled = 0 }()
+	handler := fake.newHandler(t, config)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/configmaps", bytes.NewReader([]byte("{}")))
+	// the burst allows the first attempt and one retry, the second retry is over the limit
+	if _, err := handler.RoundTrip(req); !apierrors.IsTooManyRequests(err) {
+		t.Fatalf("expected the retry to be rate limited, got %v", err)
+	}
+	if fake.requests != 2 {
+		t.Fatalf("expected 2 requests to SAE, got %d", fake.requests)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kubevela/pkg/util/k8s"
+	"github.com/kubevela/pkg/util/singleton"
+	"github.com/oam-dev/cluster-gateway/pkg/common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apiserver/pkg/server"
+	"k8s.io/apiserver/pkg/server/dynamiccertificates"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ClusterGatewayMetadataReconcilerName is the name of the post start hook
+// running the cluster-gateway metadata reconciler
+const ClusterGatewayMetadataReconcilerName = "sae-apiserver-cluster-gateway-metadata-reconciler"
+
+var clusterGatewayMetadataKeys = []string{"token", "tls.crt", "tls.key", "endpoint", "ca.crt"}
+
+var clusterGatewayMetadataRepairs = metrics.NewCounterVec(&metrics.CounterOpts{
+	Namespace:      "sae_apiserver_proxy",
+	Subsystem:      "cluster_gateway_metadata",
+	Name:           "repairs_total",
+	Help:           "Number of the SAEAPIServer secrets with drifted cluster-gateway metadata, partitioned by the result of repaired or failed.",
+	StabilityLevel: metrics.ALPHA,
+}, []string{"result"})
+
+func init() {
+	legacyregistry.MustRegister(clusterGatewayMetadataRepairs)
+}
+
+// reconcileTrigger wakes up the reconciler when the serving certificate rotates
+type reconcileTrigger chan struct{}
+
+func (in reconcileTrigger) Enqueue() {
+	select {
+	case in <- struct{}{}:
+	default:
+	}
+}
+
+// ReconcileClusterGatewayMetadata keeps the cluster-gateway metadata of all the
+// SAEAPIServer secrets in sync with the identity, the address and the serving
+// CA of the proxy, which may change after the token or the serving certificate
+// rotates or --server-address is changed
+func ReconcileClusterGatewayMetadata(hookCtx server.PostStartHookContext) error {
+	trigger := make(reconcileTrigger, 1)
+	if s := singleton.GenericAPIServer.Get(); s != nil && s.SecureServingInfo != nil {
+		if notifier, ok := s.SecureServingInfo.Cert.(dynamiccertificates.Notifier); ok {
+			notifier.AddListener(trigger)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-hookCtx.StopCh
+		cancel()
+	}()
+	go func() {
+		ticker := time.NewTicker(reconcileInterval)
+		defer ticker.Stop()
+		for {
+			reconcileClusterGatewayMetadata(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-trigger:
+			}
+		}
+	}()
+	return nil
+}
+
+func reconcileClusterGatewayMetadata(ctx context.Context) {
+	secrets := &corev1.SecretList{}
+	if err := singleton.KubeClient.Get().List(ctx, secrets, client.InNamespace(storageNamespace), client.MatchingLabels{LabelSAEAPIServer: LabelKeySAEAPIServer}); err != nil {
+		klog.ErrorS(err, "failed to list SAEAPIServer secrets")
+		return
+	}
+	for i := range secrets.Items {
+		secret := secrets.Items[i].DeepCopy()
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		attachClusterGatewayMetadata(secret)
+		drifted := clusterGatewayMetadataDrift(&secrets.Items[i], secret)
+		if len(drifted) == 0 {
+			continue
+		}
+		klog.InfoS("cluster-gateway metadata drifted", "SAEAPIServer", secret.Name, "drifted", drifted)
+		if err := singleton.KubeClient.Get().Update(ctx, secret); err != nil {
+			klog.ErrorS(err, "failed to sync cluster-gateway metadata", "SAEAPIServer", secret.Name)
+			clusterGatewayMetadataRepairs.WithLabelValues("failed").Inc()
+			recordDriftEvent(secret, corev1.EventTypeWarning, "MetadataRepairFailed",
+				fmt.Sprintf("cannot repair drifted cluster-gateway metadata %s: %v", strings.Join(drifted, ","), err))
+			continue
+		}
+		clusterGatewayMetadataRepairs.WithLabelValues("repaired").Inc()
+		recordDriftEvent(secret, corev1.EventTypeNormal, "MetadataRepaired",
+			fmt.Sprintf("repaired drifted cluster-gateway metadata %s", strings.Join(drifted, ",")))
+	}
+}
+
+// recordDriftEvent records the repair of the drifted metadata as an event of the SAEAPIServer
+func recordDriftEvent(secret *corev1.Secret, eventType, reason, message string) {
+	apiserver, err := convertSecretToSAEAPIServer(secret)
+	if err != nil {
+		return
+	}
+	apiserver.SetGroupVersionKind(GroupVersion.WithKind("SAEAPIServer"))
+	eventRecorder.Get().Event(apiserver, eventType, reason, message)
+}
+
+func clusterGatewayMetadataDrift(current, desired *corev1.Secret) []string {
+	var drifted []string
+	for _, key := range clusterGatewayMetadataKeys {
+		if !bytes.Equal(current.Data[key], desired.Data[key]) {
+			drifted = append(drifted, key)
+		}
+	}
+	if k8s.GetLabel(current, common.LabelKeyClusterCredentialType) != k8s.GetLabel(desired, common.LabelKeyClusterCredentialType) {
+		drifted = append(drifted, common.LabelKeyClusterCredentialType)
+	}
+	return drifted
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kubevela/pkg/util/singleton"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestReconcileClusterGatewayMetadata(t *testing.T) {
+	apiserver := &SAEAPIServer{ObjectMeta: metav1.ObjectMeta{Name: "sae"}}
+	apiserver.Spec.AccessKeyId, apiserver.Spec.AccessKeySecret = "ak", "sk"
+	drifted := newSAEAPIServerSecret(apiserver)
+	drifted.Data["endpoint"] = []byte("https://stale/")
+	synced := newSAEAPIServerSecret(&SAEAPIServer{ObjectMeta: metav1.ObjectMeta{Name: "synced"}})
+	setFakeKubeClient(drifted, synced)
+	recorder := record.NewFakeRecorder(10)
+	eventRecorder.Set(recorder)
+	ctx := context.Background()
+
+	reconcileClusterGatewayMetadata(ctx)
+	secret := &corev1.Secret{}
+	if err := singleton.KubeClient.Get().Get(ctx, types.NamespacedName{Namespace: storageNamespace, Name: "sae"}, secret); err != nil {
+		t.Fatal(err)
+	}
+	if endpoint := string(secret.Data["endpoint"]); !strings.HasSuffix(endpoint, "/saeapiservers/sae/proxy/") {
+		t.Fatalf("expected the endpoint to be repaired, got %s", endpoint)
+	}
+	if len(recorder.Events) != 1 {
+		t.Fatalf("expected one event for the drifted secret, got %d", len(recorder.Events))
+	}
+	if event := <-recorder.Events; !strings.Contains(event, "MetadataRepaired") || !strings.Contains(event, "endpoint") {
+		t.Fatalf("expected the repair event, got %s", event)
+	}
+
+	reconcileClusterGatewayMetadata(ctx)
+	if len(recorder.Events) != 0 {
+		t.Fatalf("expected no event once repaired, got %d", len(recorder.Events))
+	}
+}
@@ -31,7 +31,10 @@ const (
 
 var GroupVersion = schema.GroupVersion{Group: Group, Version: Version}
 
-const SAEAPIServerResource = "saeapiservers"
+const (
+	SAEAPIServerResource  = "saeapiservers"
+	SAECredentialResource = "saecredentials"
+)
 
 func init() {
 	apiruntime.Must(AddToScheme(scheme.Scheme))
@@ -40,6 +43,7 @@ func init() {
 var AddToScheme = func(scheme *runtime.Scheme) error {
 	metav1.AddToGroupVersion(scheme, GroupVersion)
 	scheme.AddKnownTypes(GroupVersion, &SAEAPIServer{}, &SAEAPIServerList{})
-	scheme.AddKnownTypes(GroupVersion, &SAEAPIServerProxyOptions{})
+	scheme.AddKnownTypes(GroupVersion, &SAEAPIServerProxyOptions{}, &SAEAPIServerHistory{})
+	scheme.AddKnownTypes(GroupVersion, &SAECredential{}, &SAECredentialList{})
 	return nil
 }
@@ -0,0 +1,230 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/utils/strings/slices"
+)
+
+var (
+	resourceVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete", "deletecollection"}
+
+	proxyRequestInfoFactory = &request.RequestInfoFactory{
+		APIPrefixes:          sets.NewString("api", "apis"),
+		GrouplessAPIPrefixes: sets.NewString("api"),
+	}
+)
+
+// Validate checks the supported resource
+func (in SAEAPIServerSupportedResource) Validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if in.Resource == "" {
+		errs = append(errs, field.Required(fldPath.Child("resource"), ""))
+	}
+	for i, verb := range in.Verbs {
+		if !slices.Contains(resourceVerbs, verb) {
+			errs = append(errs, field.NotSupported(fldPath.Child("verbs").Index(i), verb, resourceVerbs))
+		}
+	}
+	return errs
+}
+
+// matches tells if the entry covers the resource or the subresource
+func (in SAEAPIServerSupportedResource) matches(group, resource, subresource string) bool {
+	if in.Group != group {
+		return false
+	}
+	return in.Resource == resource || (subresource != "" && in.Resource == resource+"/"+subresource)
+}
+
+// supportedResource returns the entry covering the resource, which is always
+// found if no supported resources are configured
+func (in *SAEAPIServerProxyConfig) supportedResource(group, resource, subresource string) (SAEAPIServerSupportedResource, bool) {
+	if in == nil || len(in.SupportedResources) == 0 {
+		return SAEAPIServerSupportedResource{Group: group, Resource: resource}, true
+	}
+	for _, r := range in.SupportedResources {
+		if r.matches(group, resource, subresource) {
+			return r, true
+		}
+	}
+	return SAEAPIServerSupportedResource{}, false
+}
+
+func (in *SAEAPIServerProxyConfig) filtersResources() bool {
+	return in != nil && len(in.SupportedResources) > 0
+}
+
+// checkSupportedResource rejects the requests to the resources outside the
+// supported resources with NotFound, and the unsupported verbs with MethodNotSupported
+func (in *proxyHandler) checkSupportedResource(req *http.Request) error {
+	config := in.apiserver.Spec.Proxy
+	if !config.filtersResources() {
+		return nil
+	}
+	infoReq := req.Clone(req.Context())
+	infoReq.URL.Path = in.path
+	info, err := proxyRequestInfoFactory.NewRequestInfo(infoReq)
+	if err != nil || !info.IsResourceRequest {
+		return nil
+	}
+	gr := schema.GroupResource{Group: info.APIGroup, Resource: info.Resource}
+	if info.Subresource != "" {
+		gr.Resource += "/" + info.Subresource
+	}
+	supported, found := config.supportedResource(info.APIGroup, info.Resource, info.Subresource)
+	if !found {
+		return in.newNotSupportedError(http.StatusNotFound, metav1.StatusReasonNotFound, gr, info.Name,
+			fmt.Sprintf("the resource %s is not supported by SAEAPIServer %s", gr, in.apiserver.Name))
+	}
+	if len(supported.Verbs) > 0 && !slices.Contains(supported.Verbs, info.Verb) {
+		return in.newNotSupportedError(http.StatusMethodNotAllowed, metav1.StatusReasonMethodNotAllowed, gr, info.Name,
+			fmt.Sprintf("%s on the resource %s is not supported by SAEAPIServer %s, supported verbs are %s",
+				info.Verb, gr, in.apiserver.Name, strings.Join(supported.Verbs, ",")))
+	}
+	return nil
+}
+
+func (in *proxyHandler) newNotSupportedError(code int32, reason metav1.StatusReason, gr schema.GroupResource, name string, message string) *apierrors.StatusError {
+	return &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    code,
+		Reason:  reason,
+		Details: &metav1.StatusDetails{Group: gr.Group, Kind: gr.Resource, Name: name},
+		Message: message,
+	}}
+}
+
+// filterDiscovery intersects the discovery of SAE with the supported resources.
+// Groups without any supported resource are dropped from /apis, and served as
+// NotFound. The OpenAPI is not filtered.
+func (in *proxyHandler) filterDiscovery(response *http.Response) (*http.Response, error) {
+	config := in.apiserver.Spec.Proxy
+	parts := strings.Split(strings.Trim(in.path, "/"), "/")
+	if !config.filtersResources() || parts[0] == "openapi" {
+		return response, nil
+	}
+	if mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type")); mediaType != mediaTypeJSON {
+		return response, nil
+	}
+	groups := sets.NewString()
+	for _, r := range config.SupportedResources {
+		groups.Insert(r.Group)
+	}
+	data, err := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var obj interface{}
+	switch {
+	case parts[0] == "api" && len(parts) == 1:
+		versions := &metav1.APIVersions{}
+		if err = json.Unmarshal(data, versions); err == nil && !groups.Has("") {
+			versions.Versions = []string{}
+		}
+		obj = versions
+	case parts[0] == "apis" && len(parts) == 1:
+		list := &metav1.APIGroupList{}
+		if err = json.Unmarshal(data, list); err == nil {
+			var filtered []metav1.APIGroup
+			for _, group := range list.Groups {
+				if groups.Has(group.Name) {
+					filtered = append(filtered, group)
+				}
+			}
+			list.Groups = filtered
+		}
+		obj = list
+	case parts[0] == "apis" && len(parts) == 2:
+		if !groups.Has(parts[1]) {
+			return nil, in.newGroupNotSupportedError(parts[1])
+		}
+		return in.replaceBody(response, data), nil
+	default:
+		group := ""
+		if parts[0] == "apis" {
+			group = parts[1]
+		}
+		if !groups.Has(group) {
+			return nil, in.newGroupNotSupportedError(group)
+		}
+		list := &metav1.APIResourceList{}
+		if err = json.Unmarshal(data, list); err == nil {
+			list.APIResources = config.filterAPIResources(group, list.APIResources)
+		}
+		obj = list
+	}
+	if err != nil {
+		return nil, err
+	}
+	if data, err = json.Marshal(obj); err != nil {
+		return nil, err
+	}
+	response.Header.Del("ETag")
+	return in.replaceBody(response, data), nil
+}
+
+// filterAPIResources keeps the supported resources with the supported verbs
+func (in *SAEAPIServerProxyConfig) filterAPIResources(group string, resources []metav1.APIResource) []metav1.APIResource {
+	filtered := []metav1.APIResource{}
+	for _, r := range resources {
+		resource, subresource, _ := strings.Cut(r.Name, "/")
+		supported, found := in.supportedResource(group, resource, subresource)
+		if !found {
+			continue
+		}
+		if len(supported.Verbs) > 0 {
+			var verbs metav1.Verbs
+			for _, verb := range r.Verbs {
+				if slices.Contains(supported.Verbs, verb) {
+					verbs = append(verbs, verb)
+				}
+			}
+			r.Verbs = verbs
+		}
+		filtered = append(filtered, r)
+	}
+	return filtered
+}
+
+func (in *proxyHandler) newGroupNotSupportedError(group string) error {
+	return in.newNotSupportedError(http.StatusNotFound, metav1.StatusReasonNotFound, schema.GroupResource{Group: group}, "",
+		fmt.Sprintf("the group %s is not supported by SAEAPIServer %s", group, in.apiserver.Name))
+}
+
+func (in *proxyHandler) replaceBody(response *http.Response, data []byte) *http.Response {
+	response.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	response.ContentLength = int64(len(data))
+	response.Body = io.NopCloser(bytes.NewReader(data))
+	return response
+}
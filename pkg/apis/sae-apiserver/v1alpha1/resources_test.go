@@ -0,0 +1,229 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var testSupportedResources = &SAEAPIServerProxyConfig{SupportedResources: []SAEAPIServerSupportedResource{
+	{Resource: "pods", Verbs: []string{"get", "list"}},
+	{Resource: "pods/log"},
+	{Group: "apps", Resource: "deployments"},
+}}
+
+func TestCheckSupportedResource(t *testing.T) {
+	cases := map[string]struct {
+		config *SAEAPIServerProxyConfig
+		method string
+		path   string
+		code   int32
+	}{
+		"not filtered":         {method: http.MethodDelete, path: "/api/v1/namespaces/default/secrets/s"},
+		"supported":            {config: testSupportedResources, method: http.MethodGet, path: "/api/v1/namespaces/default/pods/p"},
+		"list":                 {config: testSupportedResources, method: http.MethodGet, path: "/api/v1/pods"},
+		"all verbs":            {config: testSupportedResources, method: http.MethodDelete, path: "/apis/apps/v1/namespaces/default/deployments/d"},
+		"subresource":          {config: testSupportedResources, method: http.MethodGet, path: "/api/v1/namespaces/default/pods/p/log"},
+		"resource subresource": {config: testSupportedResources, method: http.MethodPut, path: "/apis/apps/v1/namespaces/default/deployments/d/scale"},
+		"discovery":            {config: testSupportedResources, method: http.MethodGet, path: "/apis/batch/v1"},
+		"unsupported resource": {config: testSupportedResources, method: http.MethodGet, path: "/api/v1/namespaces/default/secrets/s", code: http.StatusNotFound},
+		"unsupported group":    {config: testSupportedResources, method: http.MethodGet, path: "/apis/batch/v1/jobs", code: http.StatusNotFound},
+		"unsupported sub":      {config: testSupportedResources, method: http.MethodGet, path: "/api/v1/namespaces/default/services/s/proxy", code: http.StatusNotFound},
+		"subresource verb":     {config: testSupportedResources, method: http.MethodPost, path: "/api/v1/namespaces/default/pods/p/exec", code: http.StatusMethodNotAllowed},
+		"unsupported verb":     {config: testSupportedResources, method: http.MethodDelete, path: "/api/v1/namespaces/default/pods/p", code: http.StatusMethodNotAllowed},
+		"unsupported watch":    {config: testSupportedResources, method: http.MethodGet, path: "/api/v1/pods?watch=true", code: http.StatusMethodNotAllowed},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			handler := &proxyHandler{apiserver: &SAEAPIServer{Spec: SAEAPIServerSpec{Proxy: c.config}}}
+			req := httptest.NewRequest(c.method, c.path, nil)
+			handler.path = req.URL.Path
+			err := handler.checkSupportedResource(req)
+			if c.code == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			status, ok := err.(apierrors.APIStatus)
+			if !ok || status.Status().Code != c.code {
+				t.Fatalf("expected %d, got %v", c.code, err)
+			}
+		})
+	}
+}
+
+func TestSupportedResourceValidate(t *testing.T) {
+	cases := map[string]struct {
+		resource SAEAPIServerSupportedResource
+		errs     int
+	}{
+		"valid":       {resource: SAEAPIServerSupportedResource{Group: "apps", Resource: "deployments", Verbs: []string{"get", "deletecollection"}}},
+		"subresource": {resource: SAEAPIServerSupportedResource{Resource: "pods/log"}},
+		"no resource": {resource: SAEAPIServerSupportedResource{Group: "apps"}, errs: 1},
+		"bad verb":    {resource: SAEAPIServerSupportedResource{Resource: "pods", Verbs: []string{"get", "exec"}}, errs: 1},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if errs := c.resource.Validate(field.NewPath("resource")); len(errs) != c.errs {
+				t.Fatalf("expected %d errors, got %v", c.errs, errs)
+			}
+		})
+	}
+}
+
+// filterTestDiscovery filters the discovery object served at the path
+func filterTestDiscovery(t *testing.T, config *SAEAPIServerProxyConfig, reqPath string, obj interface{}) (*http.Response, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := &proxyHandler{apiserver: &SAEAPIServer{Spec: SAEAPIServerSpec{Proxy: config}}, path: reqPath}
+	return handler.filterDiscovery(&http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {mediaTypeJSON}, "Etag": {`"upstream"`}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+	})
+}
+
+func decodeTestDiscovery(t *testing.T, response *http.Response, obj interface{}) {
+	if err := json.NewDecoder(response.Body).Decode(obj); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFilterDiscovery(t *testing.T) {
+	versions := &metav1.APIVersions{Versions: []string{"v1"}}
+	groups := &metav1.APIGroupList{Groups: []metav1.APIGroup{{Name: "apps"}, {Name: "batch"}}}
+	core := &metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{
+		{Name: "pods", Verbs: metav1.Verbs{"get", "list", "watch", "delete"}},
+		{Name: "pods/log", Verbs: metav1.Verbs{"get"}},
+		{Name: "pods/exec", Verbs: metav1.Verbs{"create"}},
+		{Name: "secrets", Verbs: metav1.Verbs{"get"}},
+	}}
+	apps := &metav1.APIResourceList{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+		{Name: "deployments", Verbs: metav1.Verbs{"get", "update"}},
+		{Name: "deployments/scale", Verbs: metav1.Verbs{"get", "update"}},
+		{Name: "statefulsets", Verbs: metav1.Verbs{"get"}},
+	}}
+
+	t.Run("not filtered", func(t *testing.T) {
+		response, err := filterTestDiscovery(t, nil, "/api/v1", core)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list := &metav1.APIResourceList{}
+		decodeTestDiscovery(t, response, list)
+		if !reflect.DeepEqual(list, core) || response.Header.Get("ETag") == "" {
+			t.Fatalf("expected the discovery as is, got %+v", list)
+		}
+	})
+	t.Run("/api", func(t *testing.T) {
+		response, err := filterTestDiscovery(t, testSupportedResources, "/api", versions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filtered := &metav1.APIVersions{}
+		decodeTestDiscovery(t, response, filtered)
+		if !reflect.DeepEqual(filtered.Versions, []string{"v1"}) || response.Header.Get("ETag") != "" {
+			t.Fatalf("expected the core group to be kept without the upstream ETag, got %+v %v", filtered, response.Header)
+		}
+		response, err = filterTestDiscovery(t, &SAEAPIServerProxyConfig{SupportedResources: []SAEAPIServerSupportedResource{{Group: "apps", Resource: "deployments"}}}, "/api", versions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decodeTestDiscovery(t, response, filtered)
+		if len(filtered.Versions) != 0 {
+			t.Fatalf("expected the core group to be dropped, got %+v", filtered)
+		}
+	})
+	t.Run("/apis", func(t *testing.T) {
+		response, err := filterTestDiscovery(t, testSupportedResources, "/apis", groups)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filtered := &metav1.APIGroupList{}
+		decodeTestDiscovery(t, response, filtered)
+		if len(filtered.Groups) != 1 || filtered.Groups[0].Name != "apps" {
+			t.Fatalf("expected only apps, got %+v", filtered)
+		}
+	})
+	t.Run("/apis/apps", func(t *testing.T) {
+		response, err := filterTestDiscovery(t, testSupportedResources, "/apis/apps", &metav1.APIGroup{Name: "apps"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		group := &metav1.APIGroup{}
+		if decodeTestDiscovery(t, response, group); group.Name != "apps" {
+			t.Fatalf("expected the group as is, got %+v", group)
+		}
+	})
+	t.Run("/api/v1", func(t *testing.T) {
+		response, err := filterTestDiscovery(t, testSupportedResources, "/api/v1", core)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list := &metav1.APIResourceList{}
+		decodeTestDiscovery(t, response, list)
+		// pods covers pods/exec, with the verbs of pods
+		expected := []metav1.APIResource{
+			{Name: "pods", Verbs: metav1.Verbs{"get", "list"}},
+			{Name: "pods/log", Verbs: metav1.Verbs{"get"}},
+			{Name: "pods/exec"},
+		}
+		if !reflect.DeepEqual(list.APIResources, expected) {
+			t.Fatalf("expected %+v, got %+v", expected, list.APIResources)
+		}
+	})
+	t.Run("/apis/apps/v1", func(t *testing.T) {
+		response, err := filterTestDiscovery(t, testSupportedResources, "/apis/apps/v1", apps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list := &metav1.APIResourceList{}
+		decodeTestDiscovery(t, response, list)
+		if !reflect.DeepEqual(list.APIResources, apps.APIResources[:2]) {
+			t.Fatalf("expected deployments and its subresources, got %+v", list.APIResources)
+		}
+	})
+	for _, reqPath := range []string{"/apis/batch", "/apis/batch/v1"} {
+		t.Run(reqPath, func(t *testing.T) {
+			if _, err := filterTestDiscovery(t, testSupportedResources, reqPath, apps); !apierrors.IsNotFound(err) {
+				t.Fatalf("expected 404 for the unsupported group, got %v", err)
+			}
+		})
+	}
+	t.Run("openapi", func(t *testing.T) {
+		response, err := filterTestDiscovery(t, testSupportedResources, "/openapi/v2", map[string]string{"swagger": "2.0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Header.Get("ETag") == "" {
+			t.Fatal("expected the OpenAPI as is")
+		}
+	})
+}
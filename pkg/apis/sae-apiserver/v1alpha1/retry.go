@@ -0,0 +1,202 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/responses"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// idempotentMethods are the methods retried on throttling and transient errors
+var idempotentMethods = sets.NewString(http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete)
+
+// errorClass is the class of the errors returned by the SAE OpenAPI
+type errorClass int
+
+const (
+	errorClassPermanent errorClass = iota
+	errorClassThrottling
+	errorClassUnavailable
+	errorClassTimeout
+)
+
+// classifyError tells if the error of the SDK is throttling or transient
+func classifyError(err error) errorClass {
+	var serverErr *sdkerrors.ServerError
+	if errors.As(err, &serverErr) {
+		switch code := serverErr.ErrorCode(); {
+		case strings.HasPrefix(code, "Throttling"), serverErr.HttpStatus() == http.StatusTooManyRequests:
+			return errorClassThrottling
+		case code == "ServiceUnavailable", serverErr.HttpStatus() == http.StatusServiceUnavailable,
+			serverErr.HttpStatus() == http.StatusBadGateway:
+			return errorClassUnavailable
+		case serverErr.HttpStatus() == http.StatusGatewayTimeout:
+			return errorClassTimeout
+		}
+		return errorClassPermanent
+	}
+	var clientErr *sdkerrors.ClientError
+	if errors.As(err, &clientErr) && clientErr.ErrorCode() == sdkerrors.TimeoutErrorCode {
+		return errorClassTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return errorClassTimeout
+	}
+	return errorClassPermanent
+}
+
+// retryAfter parses the Retry-After header of the SAE response, in either
+// seconds or http date
+func retryAfter(response *responses.CommonResponse) (time.Duration, bool) {
+	if response == nil {
+		return 0, false
+	}
+	value := http.Header(response.GetHttpHeaders()).Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t), true
+	}
+	return 0, false
+}
+
+// retryPolicy is the effective retry limits, with the flags as defaults
+// +k8s:openapi-gen=false
+type retryPolicy struct {
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+func (in *SAEAPIServerProxyConfig) getRetryPolicy() retryPolicy {
+	policy := retryPolicy{maxRetries: retryMaxRetries, initialBackoff: retryInitialBackoff, maxBackoff: retryMaxBackoff}
+	if in == nil || in.Retry == nil {
+		return policy
+	}
+	if in.Retry.MaxRetries != nil {
+		policy.maxRetries = int(*in.Retry.MaxRetries)
+	}
+	if in.Retry.InitialBackoff != nil {
+		policy.initialBackoff = in.Retry.InitialBackoff.Duration
+	}
+	if in.Retry.MaxBackoff != nil {
+		policy.maxBackoff = in.Retry.MaxBackoff.Duration
+	}
+	return policy
+}
+
+// backoff is the jittered exponential backoff before the retry of the attempt
+func (in retryPolicy) backoff(attempt int) time.Duration {
+	d := in.initialBackoff
+	for i := 0; i < attempt && d < in.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > in.maxBackoff {
+		d = in.maxBackoff
+	}
+	return wait.Jitter(d/2, 1)
+}
+
+// Validate checks the retry limits
+func (in *SAEAPIServerRetryPolicy) Validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if in == nil {
+		return errs
+	}
+	if in.MaxRetries != nil && *in.MaxRetries < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("maxRetries"), *in.MaxRetries, "must not be negative"))
+	}
+	if in.InitialBackoff != nil && in.InitialBackoff.Duration <= 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("initialBackoff"), in.InitialBackoff.Duration.String(), "must be positive"))
+	}
+	if in.MaxBackoff != nil && in.MaxBackoff.Duration <= 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("maxBackoff"), in.MaxBackoff.Duration.String(), "must be positive"))
+	}
+	if in.InitialBackoff != nil && in.MaxBackoff != nil && in.InitialBackoff.Duration > in.MaxBackoff.Duration {
+		errs = append(errs, field.Invalid(fldPath.Child("initialBackoff"), in.InitialBackoff.Duration.String(), "must not be larger than maxBackoff"))
+	}
+	return errs
+}
+
+// processRequest sends the request to SAE, retrying the idempotent methods on
+// throttling and transient errors. The request is rebuilt for every attempt.
+func (in *proxyHandler) processRequest(ctx context.Context, method string, newRequest func() *requests.CommonRequest) (*responses.CommonResponse, error) {
+	policy := in.apiserver.Spec.Proxy.getRetryPolicy()
+	if !idempotentMethods.Has(method) {
+		policy.maxRetries = 0
+	}
+	for attempt := 0; ; attempt++ {
+		// each attempt calls SAE, so the retries are rate limited as well
+		if err := in.acquireToken(ctx); err != nil {
+			return nil, err
+		}
+		response, err := in.cli.ProcessCommonRequest(newRequest())
+		if err == nil {
+			return response, nil
+		}
+		class := classifyError(err)
+		delay, found := retryAfter(response)
+		if !found {
+			delay = policy.backoff(attempt)
+		}
+		if class == errorClassPermanent || attempt >= policy.maxRetries || delay > policy.maxBackoff {
+			return nil, toAPIError(err, class, delay)
+		}
+		klog.V(4).InfoS("retrying SAE request", "SAEAPIServer", in.apiserver.Name, "path", in.path, "attempt", attempt+1, "delay", delay, "err", err)
+		select {
+		case <-ctx.Done():
+			return nil, toAPIError(err, class, delay)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// toAPIError converts the throttling and transient errors to the statuses of
+// kubernetes, so that clients can back off by themselves
+func toAPIError(err error, class errorClass, retryAfter time.Duration) error {
+	seconds := int((retryAfter + time.Second - 1) / time.Second)
+	switch class {
+	case errorClassThrottling:
+		return apierrors.NewTooManyRequests(fmt.Sprintf("throttled by SAE: %v", err), seconds)
+	case errorClassUnavailable:
+		return apierrors.NewServiceUnavailable(fmt.Sprintf("SAE is unavailable: %v", err))
+	case errorClassTimeout:
+		return apierrors.NewTimeoutError(fmt.Sprintf("SAE request timed out: %v", err), seconds)
+	default:
+		return err
+	}
+}
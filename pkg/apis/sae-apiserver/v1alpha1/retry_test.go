@@ -0,0 +1,216 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sdkerrors "github.com/aliyun/alibaba-cloud-sdk-go/sdk/errors"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/responses"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/pointer"
+)
+
+// timeoutError is a net.Error
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	cases := map[string]struct {
+		err      error
+		expected errorClass
+	}{
+		"throttling code":   {err: sdkerrors.NewServerError(http.StatusBadRequest, `{"Code":"Throttling.User"}`, ""), expected: errorClassThrottling},
+		"too many requests": {err: sdkerrors.NewServerError(http.StatusTooManyRequests, `{"Code":"Unknown"}`, ""), expected: errorClassThrottling},
+		"unavailable code":  {err: sdkerrors.NewServerError(http.StatusInternalServerError, `{"Code":"ServiceUnavailable"}`, ""), expected: errorClassUnavailable},
+		"503":               {err: sdkerrors.NewServerError(http.StatusServiceUnavailable, "", ""), expected: errorClassUnavailable},
+		"502":               {err: sdkerrors.NewServerError(http.StatusBadGateway, "", ""), expected: errorClassUnavailable},
+		"504":               {err: sdkerrors.NewServerError(http.StatusGatewayTimeout, "", ""), expected: errorClassTimeout},
+		"forbidden":         {err: sdkerrors.NewServerError(http.StatusForbidden, `{"Code":"Forbidden.RAM"}`, ""), expected: errorClassPermanent},
+		"internal error":    {err: sdkerrors.NewServerError(http.StatusInternalServerError, `{"Code":"InternalError"}`, ""), expected: errorClassPermanent},
+		"client timeout":    {err: sdkerrors.NewClientError(sdkerrors.TimeoutErrorCode, "timeout", nil), expected: errorClassTimeout},
+		"client error":      {err: sdkerrors.NewClientError(sdkerrors.JsonUnmarshalErrorCode, "invalid", nil), expected: errorClassPermanent},
+		"net timeout":       {err: fmt.Errorf("read: %w", timeoutError{}), expected: errorClassTimeout},
+		"other":             {err: io.ErrUnexpectedEOF, expected: errorClassPermanent},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if class := classifyError(c.err); class != c.expected {
+				t.Fatalf("expected class %d, got %d", c.expected, class)
+			}
+		})
+	}
+}
+
+// newCommonResponse parses the http response as the SDK does
+func newCommonResponse(t *testing.T, header http.Header) *responses.CommonResponse {
+	response := responses.NewCommonResponse()
+	err := responses.Unmarshal(response, &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"Code":"Throttling"}`))),
+	}, "JSON")
+	if err == nil {
+		t.Fatal("expected the throttling error")
+	}
+	return response
+}
+
+func TestRetryAfter(t *testing.T) {
+	cases := map[string]struct {
+		value    string
+		expected time.Duration
+		found    bool
+	}{
+		"none":     {},
+		"seconds":  {value: "3", expected: 3 * time.Second, found: true},
+		"zero":     {value: "0", found: true},
+		"negative": {value: "-1"},
+		"invalid":  {value: "soon"},
+		"date":     {value: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), expected: time.Hour, found: true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			header := http.Header{}
+			if c.value != "" {
+				header.Set("Retry-After", c.value)
+			}
+			delay, found := retryAfter(newCommonResponse(t, header))
+			if found != c.found {
+				t.Fatalf("expected found %t, got %t", c.found, found)
+			}
+			// the http date is in seconds
+			if delay > c.expected || delay < c.expected-time.Second {
+				t.Fatalf("expected delay %s, got %s", c.expected, delay)
+			}
+		})
+	}
+	if _, found := retryAfter(nil); found {
+		t.Fatal("expected no Retry-After without response")
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	var config *SAEAPIServerProxyConfig
+	if policy := config.getRetryPolicy(); policy.maxRetries != retryMaxRetries || policy.initialBackoff != retryInitialBackoff || policy.maxBackoff != retryMaxBackoff {
+		t.Fatalf("expected the flags as defaults, got %+v", policy)
+	}
+	config = &SAEAPIServerProxyConfig{Retry: &SAEAPIServerRetryPolicy{
+		MaxRetries:     pointer.Int32(0),
+		InitialBackoff: &metav1.Duration{Duration: time.Second},
+		MaxBackoff:     &metav1.Duration{Duration: 4 * time.Second},
+	}}
+	policy := config.getRetryPolicy()
+	if policy.maxRetries != 0 || policy.initialBackoff != time.Second || policy.maxBackoff != 4*time.Second {
+		t.Fatalf("unexpected policy %+v", policy)
+	}
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		// the jitter is within [d/2, d)
+		if d := policy.backoff(attempt); d < expected/2 || d >= expected {
+			t.Fatalf("expected the backoff of attempt %d within [%s, %s), got %s", attempt, expected/2, expected, d)
+		}
+	}
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	cases := map[string]struct {
+		policy *SAEAPIServerRetryPolicy
+		errs   int
+	}{
+		"nil":      {},
+		"valid":    {policy: &SAEAPIServerRetryPolicy{MaxRetries: pointer.Int32(0), InitialBackoff: &metav1.Duration{Duration: time.Second}}},
+		"negative": {policy: &SAEAPIServerRetryPolicy{MaxRetries: pointer.Int32(-1)}, errs: 1},
+		"zero":     {policy: &SAEAPIServerRetryPolicy{InitialBackoff: &metav1.Duration{}, MaxBackoff: &metav1.Duration{}}, errs: 2},
+		"inverted": {policy: &SAEAPIServerRetryPolicy{InitialBackoff: &metav1.Duration{Duration: time.Minute}, MaxBackoff: &metav1.Duration{Duration: time.Second}}, errs: 1},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if errs := c.policy.Validate(field.NewPath("retry")); len(errs) != c.errs {
+				t.Fatalf("expected %d errors, got %v", c.errs, errs)
+			}
+		})
+	}
+}
+
+func TestToAPIError(t *testing.T) {
+	err := sdkerrors.NewServerError(http.StatusTooManyRequests, `{"Code":"Throttling"}`, "")
+	throttled := toAPIError(err, errorClassThrottling, 1500*time.Millisecond)
+	if !apierrors.IsTooManyRequests(throttled) {
+		t.Fatalf("expected 429, got %v", throttled)
+	}
+	if seconds, ok := apierrors.SuggestsClientDelay(throttled); !ok || seconds != 2 {
+		t.Fatalf("expected the delay rounded up to 2s, got %d", seconds)
+	}
+	if unavailable := toAPIError(err, errorClassUnavailable, 0); !apierrors.IsServiceUnavailable(unavailable) {
+		t.Fatalf("expected 503, got %v", unavailable)
+	}
+	if timeout := toAPIError(err, errorClassTimeout, time.Second); !apierrors.IsTimeout(timeout) {
+		t.Fatalf("expected 504, got %v", timeout)
+	}
+	if permanent := toAPIError(err, errorClassPermanent, 0); permanent != err {
+		t.Fatalf("expected the original error, got %v", permanent)
+	}
+}
+
+func TestProcessRequestRetries(t *testing.T) {
+	fake := newFakeSAE(t)
+	defer fake.Close()
+	config := &SAEAPIServerProxyConfig{Retry: &SAEAPIServerRetryPolicy{
+		MaxRetries:     pointer.Int32(2),
+		InitialBackoff: &metav1.Duration{Duration: time.Millisecond},
+		MaxBackoff:     &metav1.Duration{Duration: time.Second},
+	}}
+	cases := map[string]struct {
+		method    string
+		throttled int
+		requests  int
+		succeeded bool
+	}{
+		"retried":         {method: http.MethodGet, throttled: 2, requests: 3, succeeded: true},
+		"retries used up": {method: http.MethodGet, throttled: 3, requests: 3},
+		"not idempotent":  {method: http.MethodPost, throttled: 1, requests: 1},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			fake.throttled, fake.requests = c.throttled, 0
+			defer func() { fake.throttled = 0 }()
+			handler := fake.newHandler(t, config)
+			req := httptest.NewRequest(c.method, "/api/v1/namespaces/default/configmaps", bytes.NewReader([]byte("{}")))
+			_, err := handler.RoundTrip(req)
+			if fake.requests != c.requests {
+				t.Fatalf("expected %d requests to SAE, got %d", c.requests, fake.requests)
+			}
+			if c.succeeded && err != nil {
+				t.Fatal(err)
+			}
+			if !c.succeeded && !apierrors.IsTooManyRequests(err) {
+				t.Fatalf("expected 429, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kubevela/pkg/util/k8s"
+	"github.com/kubevela/pkg/util/singleton"
+	"github.com/kubevela/pkg/util/slices"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/registry/rest"
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ resource.Object = &SAECredential{}
+var _ rest.Storage = &SAECredential{}
+var _ rest.Getter = &SAECredential{}
+var _ rest.Lister = &SAECredential{}
+var _ rest.Creater = &SAECredential{}
+var _ rest.Updater = &SAECredential{}
+var _ rest.Patcher = &SAECredential{}
+var _ rest.GracefulDeleter = &SAECredential{}
+
+const (
+	// LabelSAECredential marks the secret as the storage of SAECredential
+	LabelSAECredential = "sae.alibaba-cloud.oam.dev/credential"
+	// LabelSAECredentialName marks the SAEAPIServer secret referring to the SAECredential
+	LabelSAECredentialName = "sae.alibaba-cloud.oam.dev/credential-name"
+	// saeCredentialSecretPrefix keeps the SAECredential secrets apart from the SAEAPIServer ones
+	saeCredentialSecretPrefix = "sae-credential-"
+)
+
+// SAECredential is an aliyun AK/SK shared by SAEAPIServers through credentialRef
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type SAECredential struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the AK/SK of the aliyun account
+	Spec SAECredentialSpec `json:"spec,omitempty"`
+}
+
+// SAECredentialSpec is the specification of SAECredential
+type SAECredentialSpec struct {
+	// AccessKeyId of the aliyun account
+	AccessKeyId string `json:"accessKeyId"`
+	// AccessKeySecret of the aliyun account
+	AccessKeySecret string `json:"accessKeySecret"`
+}
+
+// SAECredentialList is a list of SAECredential
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type SAECredentialList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	// Items is the list of SAECredential
+	Items []SAECredential `json:"items"`
+}
+
+// SAECredentialReference refers to a SAECredential
+type SAECredentialReference struct {
+	// Name of the SAECredential
+	Name string `json:"name"`
+}
+
+func (in *SAECredential) Destroy() {}
+
+func (in *SAECredential) GetObjectMeta() *metav1.ObjectMeta {
+	return &in.ObjectMeta
+}
+
+func (in *SAECredential) NamespaceScoped() bool {
+	return false
+}
+
+func (in *SAECredential) New() runtime.Object {
+	return &SAECredential{}
+}
+
+func (in *SAECredential) NewList() runtime.Object {
+	return &SAECredentialList{}
+}
+
+func (in *SAECredential) GetGroupVersionResource() schema.GroupVersionResource {
+	return GroupVersion.WithResource(SAECredentialResource)
+}
+
+func (in *SAECredential) IsStorageVersion() bool {
+	return true
+}
+
+func (in *SAECredential) validate() error {
+	var errs field.ErrorList
+	if in.Spec.AccessKeyId == "" {
+		errs = append(errs, field.Required(field.NewPath("spec", "accessKeyId"), ""))
+	}
+	if in.Spec.AccessKeySecret == "" {
+		errs = append(errs, field.Required(field.NewPath("spec", "accessKeySecret"), ""))
+	}
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("SAECredential").GroupKind(), in.Name, errs)
+	}
+	return nil
+}
+
+func (in *SAECredential) Delete(ctx context.Context, name string, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions) (runtime.Object, bool, error) {
+	credential, err := in.Get(ctx, name, &metav1.GetOptions{})
+	if err != nil {
+		return nil, false, err
+	}
+	referrers, err := listSAECredentialReferrers(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(referrers) > 0 {
+		return nil, false, apierrors.NewForbidden(GroupVersion.WithResource(SAECredentialResource).GroupResource(), name,
+			fmt.Errorf("still referenced by SAEAPIServers %s", strings.Join(referrers, ",")))
+	}
+	if err = singleton.StaticClient.Get().CoreV1().Secrets(storageNamespace).Delete(ctx, saeCredentialSecretPrefix+name, metav1.DeleteOptions{}); err != nil {
+		return nil, false, err
+	}
+	return credential, true, nil
+}
+
+// listSAECredentialReferrers returns the SAEAPIServers referring to the
+// SAECredential, by the credentialRef or by any identity mapping. The label only
+// tells the former, so the specs are checked instead.
+func listSAECredentialReferrers(ctx context.Context, name string) ([]string, error) {
+	secrets := &corev1.SecretList{}
+	if err := singleton.KubeClient.Get().List(ctx, secrets, client.InNamespace(storageNamespace), client.MatchingLabels{LabelSAEAPIServer: LabelKeySAEAPIServer}); err != nil {
+		return nil, err
+	}
+	var referrers []string
+	for i := range secrets.Items {
+		apiserver, err := convertSecretToSAEAPIServer(&secrets.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range apiserver.Spec.CredentialRefs() {
+			if ref.Name == name {
+				referrers = append(referrers, apiserver.Name)
+				break
+			}
+		}
+	}
+	return referrers, nil
+}
+
+func (in *SAECredential) Update(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo, createValidation rest.ValidateObjectFunc, updateValidation rest.ValidateObjectUpdateFunc, forceAllowCreate bool, options *metav1.UpdateOptions) (runtime.Object, bool, error) {
+	credential, err := in.Get(ctx, name, &metav1.GetOptions{})
+	if err != nil {
+		return nil, false, err
+	}
+	if credential, err = objInfo.UpdatedObject(ctx, credential); err != nil {
+		return nil, false, err
+	}
+	if err = credential.(*SAECredential).validate(); err != nil {
+		return nil, false, err
+	}
+	secret := convertSAECredentialToSecret(credential.(*SAECredential))
+	if err = singleton.KubeClient.Get().Update(ctx, secret); err != nil {
+		return nil, false, err
+	}
+	return credential, true, nil
+}
+
+func (in *SAECredential) Create(ctx context.Context, obj runtime.Object, createValidation rest.ValidateObjectFunc, options *metav1.CreateOptions) (runtime.Object, error) {
+	credential := obj.(*SAECredential)
+	if err := credential.validate(); err != nil {
+		return nil, err
+	}
+	secret := convertSAECredentialToSecret(credential)
+	var err error
+	if secret, err = singleton.StaticClient.Get().CoreV1().Secrets(storageNamespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		return nil, err
+	}
+	return convertSecretToSAECredential(secret)
+}
+
+func (in *SAECredential) List(ctx context.Context, options *internalversion.ListOptions) (runtime.Object, error) {
+	secrets := &corev1.SecretList{}
+	if err := singleton.KubeClient.Get().List(ctx, secrets, client.InNamespace(storageNamespace), client.MatchingLabels{LabelSAECredential: LabelKeySAEAPIServer}); err != nil {
+		return nil, err
+	}
+	credentials := &SAECredentialList{}
+	for _, secret := range secrets.Items {
+		credential, err := convertSecretToSAECredential(secret.DeepCopy())
+		if err != nil {
+			return nil, err
+		}
+		credentials.Items = append(credentials.Items, *credential)
+	}
+	return credentials, nil
+}
+
+func (in *SAECredential) Get(ctx context.Context, name string, options *metav1.GetOptions) (runtime.Object, error) {
+	return getSAECredential(ctx, name)
+}
+
+func getSAECredential(ctx context.Context, name string) (*SAECredential, error) {
+	secret := &corev1.Secret{}
+	if err := singleton.KubeClient.Get().Get(ctx, types.NamespacedName{Namespace: storageNamespace, Name: saeCredentialSecretPrefix + name}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, apierrors.NewNotFound(GroupVersion.WithResource(SAECredentialResource).GroupResource(), name)
+		}
+		return nil, err
+	}
+	if k8s.GetLabel(secret, LabelSAECredential) != LabelKeySAEAPIServer {
+		return nil, apierrors.NewNotFound(GroupVersion.WithResource(SAECredentialResource).GroupResource(), name)
+	}
+	return convertSecretToSAECredential(secret)
+}
+
+func convertSecretToSAECredential(secret *corev1.Secret) (*SAECredential, error) {
+	if k8s.GetLabel(secret, LabelSAECredential) != LabelKeySAEAPIServer {
+		return nil, fmt.Errorf("secret %s/%s is not a SAECredential secret", storageNamespace, secret.Name)
+	}
+	credential := &SAECredential{}
+	credential.ObjectMeta = secret.ObjectMeta
+	credential.SetName(strings.TrimPrefix(secret.Name, saeCredentialSecretPrefix))
+	credential.SetNamespace("")
+	credential.Spec.AccessKeyId = string(secret.Data[IdentAccessKeyId])
+	credential.Spec.AccessKeySecret = string(secret.Data[IdentAccessKeySecret])
+	return credential, nil
+}
+
+func convertSAECredentialToSecret(credential *SAECredential) *corev1.Secret {
+	secret := &corev1.Secret{Data: map[string][]byte{}}
+	secret.ObjectMeta = credential.ObjectMeta
+	secret.SetName(saeCredentialSecretPrefix + credential.Name)
+	secret.SetNamespace(storageNamespace)
+	secret.SetLabels(filterUserMetadata(credential.GetLabels()))
+	secret.SetAnnotations(filterUserMetadata(credential.GetAnnotations()))
+	_ = k8s.AddLabel(secret, LabelSAECredential, LabelKeySAEAPIServer)
+	secret.Data[IdentAccessKeyId] = []byte(credential.Spec.AccessKeyId)
+	secret.Data[IdentAccessKeySecret] = []byte(credential.Spec.AccessKeySecret)
+	return secret
+}
+
+func (in *SAECredential) ConvertToTable(ctx context.Context, object runtime.Object, tableOptions runtime.Object) (*metav1.Table, error) {
+	return convertToTable(ctx, object, tableOptions)
+}
+
+var credentialDefinitions = []metav1.TableColumnDefinition{
+	{Name: "Name", Type: "string", Format: "name", Description: "the name of the SAECredential"},
+	{Name: "AK", Type: "string", Description: "the masked accessKeyId of the SAECredential"},
+	{Name: "Age", Type: "string", Description: "the time since the SAECredential is created"},
+}
+
+func (in *SAECredential) row() *metav1.TableRow {
+	return &metav1.TableRow{
+		Object: runtime.RawExtension{Object: in},
+		Cells:  []interface{}{in.Name, maskAccessKeyId(in.Spec.AccessKeyId), translateTimestampSince(in.CreationTimestamp)},
+	}
+}
+
+func (in *SAECredential) ToTable(context.Context) *metav1.Table {
+	return &metav1.Table{
+		ColumnDefinitions: credentialDefinitions,
+		Rows:              []metav1.TableRow{*in.row()},
+	}
+}
+
+func (in *SAECredentialList) ToTable(context.Context) *metav1.Table {
+	return &metav1.Table{
+		ListMeta:          metav1.ListMeta{ResourceVersion: in.ResourceVersion},
+		ColumnDefinitions: credentialDefinitions,
+		Rows: slices.Map(in.Items, func(item SAECredential) metav1.TableRow {
+			return *item.row()
+		}),
+	}
+}
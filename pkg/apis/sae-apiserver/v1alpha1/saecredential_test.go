@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/kubevela/pkg/util/singleton"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMain(m *testing.M) {
+	// the secrets are built without the kubeconfig of a hub
+	singleton.KubeConfig.Set(&rest.Config{})
+	os.Exit(m.Run())
+}
+
+// setFakeKubeClient serves the storage from the objects
+func setFakeKubeClient(objs ...client.Object) {
+	singleton.KubeClient.Set(fake.NewClientBuilder().WithObjects(objs...).Build())
+}
+
+func newSAEAPIServerSecret(apiserver *SAEAPIServer) *corev1.Secret {
+	if apiserver.Spec.Region == "" {
+		apiserver.Spec.Region = DefaultSAEAPIServerRegion
+	}
+	return convertSAEAPIServerToSecret(apiserver)
+}
+
+func TestSecretKindsAreKeptApart(t *testing.T) {
+	apiserver := &SAEAPIServer{ObjectMeta: metav1.ObjectMeta{Name: "sae-credential-prod"}}
+	apiserver.Spec.AccessKeyId, apiserver.Spec.AccessKeySecret = "ak", "sk"
+	credential := &SAECredential{ObjectMeta: metav1.ObjectMeta{Name: "shared"}}
+	credential.Spec.AccessKeyId, credential.Spec.AccessKeySecret = "ak", "sk"
+	setFakeKubeClient(newSAEAPIServerSecret(apiserver), convertSAECredentialToSecret(credential))
+	ctx := context.Background()
+
+	if _, err := getSAECredential(ctx, "prod"); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected the SAEAPIServer secret not to be served as SAECredential, got %v", err)
+	}
+	if _, err := (&SAEAPIServer{}).Get(ctx, saeCredentialSecretPrefix+"shared", &metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected the SAECredential secret not to be served as SAEAPIServer, got %v", err)
+	}
+	if _, err := getSAECredential(ctx, "shared"); err != nil {
+		t.Fatal(err)
+	}
+	if err := apiserver.validate(); !apierrors.IsInvalid(err) {
+		t.Fatalf("expected the reserved prefix to be rejected, got %v", err)
+	}
+}
+
+func TestListSAECredentialReferrers(t *testing.T) {
+	newAPIServer := func(name string, ref string, mappingRefs ...string) *corev1.Secret {
+		apiserver := &SAEAPIServer{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if ref != "" {
+			apiserver.Spec.CredentialRef = &SAECredentialReference{Name: ref}
+		} else {
+			apiserver.Spec.AccessKeyId, apiserver.Spec.AccessKeySecret = "ak", "sk"
+		}
+		for _, mappingRef := range mappingRefs {
+			apiserver.Spec.IdentityMappings = append(apiserver.Spec.IdentityMappings, SAEAPIServerIdentityMapping{
+				Users: []string{"alice"}, CredentialRef: &SAECredentialReference{Name: mappingRef},
+			})
+		}
+		return newSAEAPIServerSecret(apiserver)
+	}
+	setFakeKubeClient(
+		newAPIServer("spec", "shared"),
+		newAPIServer("mapping", "", "other", "shared"),
+		newAPIServer("unrelated", "other"),
+	)
+	referrers, err := listSAECredentialReferrers(context.Background(), "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"mapping", "spec"}; !reflect.DeepEqual(referrers, expected) {
+		t.Fatalf("expected the referrers %v, got %v", expected, referrers)
+	}
+}
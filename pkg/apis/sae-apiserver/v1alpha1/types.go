@@ -18,17 +18,23 @@ package v1alpha1
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/apiserver/pkg/registry/rest"
 	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/kubevela/pkg/util/k8s"
 	"github.com/kubevela/pkg/util/singleton"
 )
 
@@ -41,13 +47,16 @@ var _ rest.Updater = &SAEAPIServer{}
 var _ rest.Patcher = &SAEAPIServer{}
 var _ rest.GracefulDeleter = &SAEAPIServer{}
 
-// SAEAPIServer
+// SAEAPIServer registers a SAE APIServer, which is served as a cluster in cluster-gateway
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type SAEAPIServer struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
+	// Spec is the credential and location of the SAE APIServer
 	Spec SAEAPIServerSpec `json:"spec,omitempty"`
+	// Status is the observed state of the SAE APIServer
+	Status SAEAPIServerStatus `json:"status,omitempty"`
 }
 
 func (in *SAEAPIServer) Destroy() {}
@@ -77,26 +86,227 @@ func (in *SAEAPIServer) IsStorageVersion() bool {
 }
 
 func (in *SAEAPIServer) GetArbitrarySubResources() []resource.ArbitrarySubResource {
-	return []resource.ArbitrarySubResource{&SAEAPIServerProxy{}}
+	return []resource.ArbitrarySubResource{&SAEAPIServerProxy{}, &SAEAPIServerHistoryStorage{}}
 }
 
-// SAEAPIServerList
+// SAEAPIServerList is a list of SAEAPIServer
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type SAEAPIServerList struct {
 	metav1.TypeMeta `json:",inline"`
 	metav1.ListMeta `json:"metadata,omitempty"`
 
+	// Items is the list of SAEAPIServer
 	Items []SAEAPIServer `json:"items"`
 }
 
+// SAEAPIServerSpec is the specification of SAEAPIServer
 type SAEAPIServerSpec struct {
 	SAEAPIServerCredential `json:",inline"`
-	Region                 string `json:"region,omitempty"`
+	// Region of the SAE APIServer, defaults to cn-hangzhou
+	Region string `json:"region,omitempty"`
+	// Endpoint overrides the SAE OpenAPI endpoint resolved from the region. It
+	// must be allowed by --allowed-endpoints, as the signed requests are sent to it.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Regions are the additional regions sharing the credential, which are
+	// served under proxy/regions/<region>/
+	// +listType=set
+	Regions []string `json:"regions,omitempty"`
+	// Proxy tunes how the requests are proxied through SAE
+	Proxy *SAEAPIServerProxyConfig `json:"proxy,omitempty"`
+	// IdentityMappings picks the credential by the caller, the first matched
+	// mapping applies and the credential of the SAEAPIServer is the default
+	// +listType=atomic
+	IdentityMappings []SAEAPIServerIdentityMapping `json:"identityMappings,omitempty"`
 }
 
+// SAEAPIServerIdentityMapping maps the users and groups of the hub to the
+// credential used to access SAE on their behalf
+type SAEAPIServerIdentityMapping struct {
+	// Users are the names of the matched users
+	// +listType=set
+	Users []string `json:"users,omitempty"`
+	// Groups are the names of the matched groups
+	// +listType=set
+	Groups []string `json:"groups,omitempty"`
+	// CredentialRef uses the AK/SK of the SAECredential for the matched callers
+	CredentialRef *SAECredentialReference `json:"credentialRef,omitempty"`
+	// RoleArn is the RAM role assumed for the matched callers, with the AK/SK of
+	// the credentialRef, or with the credential of the SAEAPIServer if not set
+	RoleArn string `json:"roleArn,omitempty"`
+	// Policy further restricts the permissions of the assumed role, not
+	// supported by OIDC
+	Policy string `json:"policy,omitempty"`
+}
+
+// SAEAPIServerProxyConfig tunes how the requests are proxied through the SAE VirtualServerProxy
+type SAEAPIServerProxyConfig struct {
+	// RequestBodyEncoding is how the request body is put into the SAE envelope,
+	// one of Text, Auto and Base64. Text, the default, puts the body into the
+	// content as is, which is the envelope documented by the SAE VirtualServerProxy
+	// API. Auto encodes the body in base64 when it is not valid UTF-8 text, and
+	// Base64 always does, marked by the contentEncoding field of the envelope,
+	// which is not documented by SAE. They must only be set if the SAE endpoint
+	// decodes that field.
+	RequestBodyEncoding BodyEncoding `json:"requestBodyEncoding,omitempty"`
+	// CompressRequests sends the request bodies larger than the compression
+	// threshold of the proxy compressed by gzip, marked by the contentEncoding
+	// field of the envelope, unless the encoding is set to Text. Like the Auto
+	// and Base64 encodings, it must only be set if the SAE endpoint decodes that
+	// field.
+	CompressRequests bool `json:"compressRequests,omitempty"`
+	// Retry limits the retries of the idempotent requests on throttling and
+	// transient errors of SAE
+	Retry *SAEAPIServerRetryPolicy `json:"retry,omitempty"`
+	// RateLimit limits the requests proxied to SAE, which has QPS limits per account
+	RateLimit *SAEAPIServerRateLimit `json:"rateLimit,omitempty"`
+	// SupportedResources limits the resources advertised in the discovery and
+	// served through the proxy, all resources are allowed if empty
+	// +listType=atomic
+	SupportedResources []SAEAPIServerSupportedResource `json:"supportedResources,omitempty"`
+	// Headers extends the headers passed between the clients and SAE
+	Headers *SAEAPIServerHeaderPolicy `json:"headers,omitempty"`
+}
+
+// SAEAPIServerHeaderPolicy lists the headers passed on top of the default
+// allow-lists. Credentials, impersonation and hop-by-hop headers are never passed.
+type SAEAPIServerHeaderPolicy struct {
+	// Request are the extra request headers forwarded to SAE
+	// +listType=set
+	Request []string `json:"request,omitempty"`
+	// Response are the extra response headers of SAE returned to the clients
+	// +listType=set
+	Response []string `json:"response,omitempty"`
+}
+
+// SAEAPIServerSupportedResource is a resource supported by the SAE APIServer
+type SAEAPIServerSupportedResource struct {
+	// Group of the resource, empty for the core group
+	Group string `json:"group,omitempty"`
+	// Resource is the plural name of the resource, such as deployments, which
+	// covers the subresources as well, or a subresource only, such as pods/log
+	Resource string `json:"resource"`
+	// Verbs limits the verbs of the resource, such as get and list, all verbs
+	// are allowed if empty
+	// +listType=set
+	Verbs []string `json:"verbs,omitempty"`
+}
+
+// SAEAPIServerRetryPolicy limits the retries with jittered exponential backoff
+type SAEAPIServerRetryPolicy struct {
+	// MaxRetries defaults to --retry-max-retries, 0 disables retries
+	MaxRetries *int32 `json:"maxRetries,omitempty"`
+	// InitialBackoff defaults to --retry-initial-backoff
+	InitialBackoff *metav1.Duration `json:"initialBackoff,omitempty"`
+	// MaxBackoff defaults to --retry-max-backoff. Retry-After longer than it is
+	// not waited for.
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
+}
+
+// SAEAPIServerRateLimit limits the requests proxied to SAE by token buckets
+type SAEAPIServerRateLimit struct {
+	// QPS defaults to --rate-limit-qps, 0 disables the limit
+	QPS *int32 `json:"qps,omitempty"`
+	// Burst defaults to --rate-limit-burst
+	Burst *int32 `json:"burst,omitempty"`
+	// PerUser limits each caller by a token bucket of its own as well, so that
+	// one caller cannot starve the others. Defaults to --rate-limit-per-user.
+	PerUser *bool `json:"perUser,omitempty"`
+	// QueueTimeout is how long over-limit requests wait for a token, those that
+	// would wait longer are rejected with 429 and Retry-After. Defaults to
+	// --rate-limit-queue-timeout, 0 rejects them at once.
+	QueueTimeout *metav1.Duration `json:"queueTimeout,omitempty"`
+}
+
+// SAEAPIServerCredential holds exactly one credential source. The inline
+// accessKeyId/accessKeySecret pair is the original v1alpha1 form, the other
+// sources are introduced together with v1beta1.
 type SAEAPIServerCredential struct {
-	AccessKeyId     string `json:"accessKeyId"`
+	// AccessKeyId of the aliyun account
+	AccessKeyId string `json:"accessKeyId,omitempty"`
+	// AccessKeySecret of the aliyun account
+	AccessKeySecret string `json:"accessKeySecret,omitempty"`
+
+	// SecretRef loads the AK/SK from a secret
+	SecretRef *SAEAPIServerSecretReference `json:"secretRef,omitempty"`
+	// STS assumes a RAM role with the AK/SK
+	STS *SAEAPIServerSTSCredential `json:"sts,omitempty"`
+	// OIDC assumes a RAM role with the OIDC token of the proxy
+	OIDC *SAEAPIServerOIDCCredential `json:"oidc,omitempty"`
+	// CredentialRef uses the AK/SK of a SAECredential shared by SAEAPIServers
+	CredentialRef *SAECredentialReference `json:"credentialRef,omitempty"`
+}
+
+// SAEAPIServerSecretReference refers to a secret holding the AK/SK
+type SAEAPIServerSecretReference struct {
+	// Namespace of the secret, which must be the storage namespace if set, as
+	// the proxy only reads the secrets there
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the secret
+	Name string `json:"name"`
+	// AccessKeyIdKey is the key of accessKeyId in the secret, defaults to accessKeyId
+	AccessKeyIdKey string `json:"accessKeyIdKey,omitempty"`
+	// AccessKeySecretKey is the key of accessKeySecret in the secret, defaults to accessKeySecret
+	AccessKeySecretKey string `json:"accessKeySecretKey,omitempty"`
+}
+
+// SAEAPIServerSTSCredential assumes the RAM role with the given AK/SK
+type SAEAPIServerSTSCredential struct {
+	// AccessKeyId of the RAM user allowed to assume the role
+	AccessKeyId string `json:"accessKeyId"`
+	// AccessKeySecret of the RAM user allowed to assume the role
 	AccessKeySecret string `json:"accessKeySecret"`
+	// RoleArn of the RAM role to assume
+	RoleArn string `json:"roleArn"`
+	// RoleSessionName defaults to sae-apiserver-proxy
+	RoleSessionName string `json:"roleSessionName,omitempty"`
+	// Policy further restricts the permissions of the assumed role
+	Policy string `json:"policy,omitempty"`
+}
+
+// SAEAPIServerOIDCCredential assumes the RAM role with the OIDC token mounted
+// into the proxy, such as the one issued by ACK RRSA
+type SAEAPIServerOIDCCredential struct {
+	// RoleArn of the RAM role to assume
+	RoleArn string `json:"roleArn"`
+	// OIDCProviderArn of the OIDC identity provider registered in RAM
+	OIDCProviderArn string `json:"oidcProviderArn"`
+	// OIDCTokenFile defaults to the path in ALIBABA_CLOUD_OIDC_TOKEN_FILE
+	OIDCTokenFile string `json:"oidcTokenFile,omitempty"`
+	// RoleSessionName defaults to sae-apiserver-proxy
+	RoleSessionName string `json:"roleSessionName,omitempty"`
+	// STSEndpoint defaults to sts.aliyuncs.com, and must be allowed by
+	// --allowed-endpoints, as the OIDC token of the proxy is sent to it
+	STSEndpoint string `json:"stsEndpoint,omitempty"`
+}
+
+// SAEAPIServerStatus is derived from the storage secret, it is read-only
+type SAEAPIServerStatus struct {
+	// ProxyEndpoint is the endpoint registered to cluster-gateway
+	ProxyEndpoint string `json:"proxyEndpoint,omitempty"`
+	// CredentialType is the credential type used by cluster-gateway to access the proxy
+	CredentialType string `json:"credentialType,omitempty"`
+	// RegionProxyEndpoints are the proxy endpoints of the additional regions
+	RegionProxyEndpoints map[string]string `json:"regionProxyEndpoints,omitempty"`
+}
+
+func (in *SAEAPIServer) validate() error {
+	var errs field.ErrorList
+	if strings.HasPrefix(in.Name, saeCredentialSecretPrefix) {
+		// the secret would collide with the one of the SAECredential
+		errs = append(errs, field.Invalid(field.NewPath("metadata", "name"), in.Name,
+			fmt.Sprintf("must not start with %s, which is reserved for SAECredentials", saeCredentialSecretPrefix)))
+	}
+	errs = append(errs, in.Spec.SAEAPIServerCredential.Validate(field.NewPath("spec"))...)
+	errs = append(errs, ValidateEndpoint(in.Spec.Endpoint, field.NewPath("spec", "endpoint"))...)
+	errs = append(errs, ValidateRegions(in.Spec.Region, in.Spec.Regions, field.NewPath("spec", "regions"))...)
+	errs = append(errs, in.Spec.Proxy.Validate(field.NewPath("spec", "proxy"))...)
+	for i, mapping := range in.Spec.IdentityMappings {
+		errs = append(errs, mapping.Validate(field.NewPath("spec", "identityMappings").Index(i))...)
+	}
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("SAEAPIServer").GroupKind(), in.Name, errs)
+	}
+	return nil
 }
 
 func (in *SAEAPIServer) Delete(ctx context.Context, name string, deleteValidation rest.ValidateObjectFunc, options *metav1.DeleteOptions) (runtime.Object, bool, error) {
@@ -107,27 +317,82 @@ func (in *SAEAPIServer) Delete(ctx context.Context, name string, deleteValidatio
 	if err = singleton.StaticClient.Get().CoreV1().Secrets(storageNamespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
 		return nil, false, err
 	}
+	proxyClients.evict(name)
+	proxyDiscovery.evict(name)
+	recordEvent(apiserver.(*SAEAPIServer), newHistoryEntry(ctx, ActionDelete, apiserver.(*SAEAPIServer), nil))
 	return apiserver, true, nil
 }
 
+// validateCredentialRef checks that the referred SAECredentials exist
+func (in *SAEAPIServer) validateCredentialRef(ctx context.Context) error {
+	var errs field.ErrorList
+	check := func(fldPath *field.Path, ref *SAECredentialReference) error {
+		if ref == nil {
+			return nil
+		}
+		if _, err := getSAECredential(ctx, ref.Name); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			errs = append(errs, field.NotFound(fldPath, ref.Name))
+		}
+		return nil
+	}
+	if err := check(field.NewPath("spec", "credentialRef", "name"), in.Spec.CredentialRef); err != nil {
+		return err
+	}
+	for i, mapping := range in.Spec.IdentityMappings {
+		if err := check(field.NewPath("spec", "identityMappings").Index(i).Child("credentialRef", "name"), mapping.CredentialRef); err != nil {
+			return err
+		}
+	}
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("SAEAPIServer").GroupKind(), in.Name, errs)
+	}
+	return nil
+}
+
 func (in *SAEAPIServer) Update(ctx context.Context, name string, objInfo rest.UpdatedObjectInfo, createValidation rest.ValidateObjectFunc, updateValidation rest.ValidateObjectUpdateFunc, forceAllowCreate bool, options *metav1.UpdateOptions) (runtime.Object, bool, error) {
-	apiserver, err := in.Get(ctx, name, &metav1.GetOptions{})
+	previous, err := in.Get(ctx, name, &metav1.GetOptions{})
+	if err != nil {
+		return nil, false, err
+	}
+	apiserver, err := objInfo.UpdatedObject(ctx, previous.DeepCopyObject())
 	if err != nil {
 		return nil, false, err
 	}
-	if apiserver, err = objInfo.UpdatedObject(ctx, apiserver); err != nil {
+	if err = apiserver.(*SAEAPIServer).validate(); err != nil {
+		return nil, false, err
+	}
+	if err = apiserver.(*SAEAPIServer).validateCredentialRef(ctx); err != nil {
 		return nil, false, err
 	}
 	secret := convertSAEAPIServerToSecret(apiserver.(*SAEAPIServer))
+	entry := newHistoryEntry(ctx, ActionUpdate, previous.(*SAEAPIServer), apiserver.(*SAEAPIServer))
+	appendHistory(secret, previous.(*SAEAPIServer), entry)
 	if err = singleton.KubeClient.Get().Update(ctx, secret); err != nil {
 		return nil, false, err
 	}
+	proxyClients.evict(name)
+	proxyDiscovery.evict(name)
+	if apiserver, err = convertSecretToSAEAPIServer(secret); err != nil {
+		return nil, false, err
+	}
+	recordEvent(apiserver.(*SAEAPIServer), entry)
 	return apiserver, true, nil
 }
 
 func (in *SAEAPIServer) Create(ctx context.Context, obj runtime.Object, createValidation rest.ValidateObjectFunc, options *metav1.CreateOptions) (runtime.Object, error) {
 	apiserver := obj.(*SAEAPIServer)
+	if err := apiserver.validate(); err != nil {
+		return nil, err
+	}
+	if err := apiserver.validateCredentialRef(ctx); err != nil {
+		return nil, err
+	}
 	secret := convertSAEAPIServerToSecret(apiserver)
+	entry := newHistoryEntry(ctx, ActionCreate, nil, apiserver)
+	appendHistory(secret, nil, entry)
 	var err error
 	if secret, err = singleton.StaticClient.Get().CoreV1().Secrets(storageNamespace).Create(ctx, secret, metav1.CreateOptions{}); err != nil {
 		return nil, err
@@ -135,12 +400,19 @@ func (in *SAEAPIServer) Create(ctx context.Context, obj runtime.Object, createVa
 	if apiserver, err = convertSecretToSAEAPIServer(secret); err != nil {
 		return nil, err
 	}
+	recordEvent(apiserver, entry)
 	return apiserver, err
 }
 
 func (in *SAEAPIServer) List(ctx context.Context, options *internalversion.ListOptions) (runtime.Object, error) {
+	selector := labels.SelectorFromSet(labels.Set{LabelSAEAPIServer: LabelKeySAEAPIServer})
+	if options != nil && options.LabelSelector != nil {
+		if reqs, selectable := options.LabelSelector.Requirements(); selectable {
+			selector = selector.Add(reqs...)
+		}
+	}
 	secrets := &corev1.SecretList{}
-	if err := singleton.KubeClient.Get().List(ctx, secrets, client.InNamespace(storageNamespace), client.MatchingLabels{LabelSAEAPIServer: LabelKeySAEAPIServer}); err != nil {
+	if err := singleton.KubeClient.Get().List(ctx, secrets, client.InNamespace(storageNamespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
 		return nil, err
 	}
 	apiservers := &SAEAPIServerList{}
@@ -159,5 +431,9 @@ func (in *SAEAPIServer) Get(ctx context.Context, name string, options *metav1.Ge
 	if err := singleton.KubeClient.Get().Get(ctx, types.NamespacedName{Namespace: storageNamespace, Name: name}, secret); err != nil {
 		return nil, err
 	}
+	if k8s.GetLabel(secret, LabelSAEAPIServer) != LabelKeySAEAPIServer {
+		// the secret of another kind, such as SAECredential, is never served
+		return nil, apierrors.NewNotFound(GroupVersion.WithResource(SAEAPIServerResource).GroupResource(), name)
+	}
 	return convertSecretToSAEAPIServer(secret)
 }
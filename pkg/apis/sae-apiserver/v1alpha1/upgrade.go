@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+)
+
+// podStreamPathPattern matches the pod subresources served by streaming
+// connections, with the name of the pod and the subresource captured
+var podStreamPathPattern = regexp.MustCompile(`/api/v1/namespaces/[^/]+/pods/([^/]+)/(exec|attach|portforward)$`)
+
+// StatusReasonNotImplemented means the request is valid for Kubernetes but not
+// implemented by SAE
+const StatusReasonNotImplemented metav1.StatusReason = "NotImplemented"
+
+// isStreamRequest tells if the request asks for a SPDY/WebSocket upgrade, as
+// exec, attach and port-forward do. The plain requests to those subresources
+// are left to SAE, which answers them as the kube-apiserver does.
+func isStreamRequest(req *http.Request) bool {
+	return httpstream.IsUpgradeRequest(req)
+}
+
+// newStreamNotImplementedError explains that streaming connections cannot be
+// tunneled, as the SAE VirtualServerProxy only serves request/response calls
+func (in *proxyHandler) newStreamNotImplementedError(reqPath string) *apierrors.StatusError {
+	verb, details := "streaming connections", (*metav1.StatusDetails)(nil)
+	if matches := podStreamPathPattern.FindStringSubmatch(reqPath); matches != nil {
+		verb, details = matches[2], &metav1.StatusDetails{Kind: "pods", Name: matches[1]}
+	}
+	return &apierrors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusNotImplemented,
+		Reason:  StatusReasonNotImplemented,
+		Details: details,
+		Message: fmt.Sprintf("%s is not supported by SAEAPIServer %s, as SAE only serves request/response calls without exec, attach or port-forward",
+			verb, in.apiserver.Name),
+	}}
+}
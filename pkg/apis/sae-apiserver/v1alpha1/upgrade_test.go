@@ -0,0 +1,107 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestServeHTTPStreamNotImplemented(t *testing.T) {
+	fake := newFakeSAE(t)
+	defer fake.Close()
+	upgrades := map[string]http.Header{
+		"spdy":      {"Connection": {"Upgrade"}, "Upgrade": {"SPDY/3.1"}},
+		"websocket": {"Connection": {"keep-alive, Upgrade"}, "Upgrade": {"websocket"}},
+	}
+	paths := map[string]struct {
+		path, query string
+		// pod is the name in the details, empty for the requests other than pods
+		pod string
+	}{
+		"exec":        {path: "/api/v1/namespaces/default/pods/web/exec", query: "command=sh&stdin=true&tty=true", pod: "web"},
+		"attach":      {path: "/api/v1/namespaces/default/pods/web/attach", query: "stdout=true", pod: "web"},
+		"portforward": {path: "/api/v1/namespaces/default/pods/web/portforward", query: "ports=8080", pod: "web"},
+		"service":     {path: "/api/v1/namespaces/default/services/web/proxy/ws"},
+	}
+	for upgradeName, header := range upgrades {
+		for name, p := range paths {
+			t.Run(upgradeName+"/"+name, func(t *testing.T) {
+				responder := &errorResponder{}
+				handler := fake.newHandler(t, nil)
+				handler.path, handler.responder = p.path, responder
+				req := httptest.NewRequest(http.MethodGet, p.path+"?"+p.query, nil)
+				for key, values := range header {
+					req.Header[key] = values
+				}
+				requests := fake.requests
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+				if fake.requests != requests {
+					t.Fatalf("expected the upgrade not to be sent to SAE")
+				}
+				statusErr, ok := responder.err.(*apierrors.StatusError)
+				if !ok {
+					t.Fatalf("expected the status error, got %v", responder.err)
+				}
+				status := statusErr.ErrStatus
+				if status.Code != http.StatusNotImplemented || status.Reason != StatusReasonNotImplemented {
+					t.Fatalf("expected 501 NotImplemented, got %d %s", status.Code, status.Reason)
+				}
+				if p.pod == "" {
+					if status.Details != nil {
+						t.Fatalf("expected no details, got %+v", status.Details)
+					}
+					return
+				}
+				if status.Details == nil || status.Details.Kind != "pods" || status.Details.Name != p.pod {
+					t.Fatalf("expected the details of pod %s, got %+v", p.pod, status.Details)
+				}
+			})
+		}
+	}
+}
+
+func TestServeHTTPPlainStreamSubresources(t *testing.T) {
+	fake := newFakeSAE(t)
+	defer fake.Close()
+	for _, path := range []string{
+		"/api/v1/namespaces/default/pods/web/exec",
+		"/api/v1/namespaces/default/pods/web/attach",
+		"/api/v1/namespaces/default/pods/web/portforward",
+	} {
+		t.Run(path, func(t *testing.T) {
+			responder := &errorResponder{}
+			handler := fake.newHandler(t, nil)
+			handler.path, handler.responder = path, responder
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			// a Connection header without Upgrade is not an upgrade
+			req.Header.Set("Connection", "keep-alive")
+			requests := fake.requests
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+			if responder.err != nil {
+				t.Fatalf("expected the plain request not to be rejected, got %v", responder.err)
+			}
+			if fake.requests != requests+1 || recorder.Code != http.StatusOK {
+				t.Fatalf("expected the plain request to be sent to SAE, got %d", recorder.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,667 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog/v2"
+)
+
+// watchQueryKeys are the query parameters of watch, which are dropped when
+// listing through the SAE VirtualServerProxy
+var watchQueryKeys = []string{"watch", "allowWatchBookmarks", "timeoutSeconds", "resourceVersion", "resourceVersionMatch", "sendInitialEvents"}
+
+func isWatchRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	watch, _ := strconv.ParseBool(req.URL.Query().Get("watch"))
+	return watch
+}
+
+// NewLongRunningRequestCheck extends the long-running check of the apiserver
+// with the watch and the log follow emulated through the proxy subresource,
+// which are otherwise gets of the subresource and cut off at the request timeout
+func NewLongRunningRequestCheck(delegate apirequest.LongRunningRequestCheck) apirequest.LongRunningRequestCheck {
+	return func(r *http.Request, requestInfo *apirequest.RequestInfo) bool {
+		if delegate != nil && delegate(r, requestInfo) {
+			return true
+		}
+		return isLongRunningProxyRequest(r, requestInfo)
+	}
+}
+
+func isLongRunningProxyRequest(r *http.Request, requestInfo *apirequest.RequestInfo) bool {
+	if !requestInfo.IsResourceRequest || requestInfo.APIGroup != Group ||
+		requestInfo.Resource != SAEAPIServerResource || requestInfo.Subresource != "proxy" {
+		return false
+	}
+	return isWatchRequest(r) || isFollowLogRequest(r, proxiedPath(r.URL.Path))
+}
+
+// proxiedPath is the path after the proxy subresource, such as /api/v1/pods
+func proxiedPath(p string) string {
+	if loc := proxyPathPattern.FindStringIndex(p); loc != nil {
+		return p[loc[1]:]
+	}
+	return p
+}
+
+// +k8s:openapi-gen=false
+type watchList struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   metav1.ListMeta   `json:"metadata"`
+	Items      []json.RawMessage `json:"items"`
+}
+
+// +k8s:openapi-gen=false
+type watchObject struct {
+	// raw is the object as listed, which the snapshots do not keep
+	raw             json.RawMessage
+	uid             string
+	resourceVersion string
+}
+
+// +k8s:openapi-gen=false
+type watchEvent struct {
+	Type   watch.EventType `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// serveWatch emulates watch on top of the request/response SAE VirtualServerProxy.
+// It polls the list, diffs the results and emits the changes as a chunked
+// watch stream, until the client disconnects or the watch times out.
+func (in *proxyHandler) serveWatch(writer http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	bookmarks, _ := strconv.ParseBool(query.Get("allowWatchBookmarks"))
+	snapshotKey := in.watchSnapshotKey(query)
+	var since map[string]watchObject
+	if rv := query.Get("resourceVersion"); rv != "" && rv != "0" {
+		// the changes since an unknown resourceVersion cannot be told, the client must relist
+		var found bool
+		if since, found = proxyWatchSnapshots.get(snapshotKey, rv); !found {
+			in.responder.Error(apierrors.NewResourceExpired(fmt.Sprintf("too old resource version: %s", rv)))
+			return
+		}
+	}
+	timeout := watchTimeout
+	if seconds, err := strconv.ParseInt(query.Get("timeoutSeconds"), 10, 64); err == nil && seconds > 0 {
+		timeout = time.Duration(seconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+
+	listReq := req.Clone(ctx)
+	listReq.Body = http.NoBody
+	for _, key := range watchQueryKeys {
+		query.Del(key)
+	}
+	listReq.URL.RawQuery = query.Encode()
+	// the diff works on json only
+	listReq.Header.Set("Accept", "application/json")
+
+	// the first list is checked before the status is sent, so that errors are returned as usual
+	list, err := in.list(listReq)
+	if err != nil {
+		in.responder.Error(err)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(http.StatusOK)
+	flusher, _ := writer.(http.Flusher)
+	encoder := json.NewEncoder(writer)
+	emit := func(eventType watch.EventType, obj json.RawMessage) bool {
+		if err := encoder.Encode(&watchEvent{Type: eventType, Object: obj}); err != nil {
+			klog.ErrorS(err, "failed to write watch event", "SAEAPIServer", in.apiserver.Name, "path", in.path)
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+
+	// without resourceVersion, all the existing objects are sent as ADDED like the
+	// kube-apiserver, otherwise the changes since the snapshot of the resourceVersion
+	known := map[string]watchObject{}
+	if since != nil {
+		known = since
+	}
+	if !emitEvents(emit, diffWatchObjects(known, list.objects(), list.tombstone)) {
+		return
+	}
+	known = list.objects()
+	proxyWatchSnapshots.add(snapshotKey, list.Metadata.ResourceVersion, known)
+
+	poll := time.NewTicker(watchPollInterval)
+	defer poll.Stop()
+	bookmark := time.NewTicker(watchBookmarkInterval)
+	defer bookmark.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-bookmark.C:
+			if bookmarks && !emit(watch.Bookmark, list.bookmark()) {
+				return
+			}
+		case <-poll.C:
+			if list, err = in.list(listReq); err != nil {
+				if ctx.Err() == nil {
+					status := apierrors.NewInternalError(err).Status()
+					if statusErr, ok := err.(apierrors.APIStatus); ok {
+						status = statusErr.Status()
+					}
+					raw, _ := json.Marshal(&status)
+					emit(watch.Error, raw)
+				}
+				return
+			}
+			current := list.objects()
+			events := diffWatchObjects(known, current, list.tombstone)
+			if !emitEvents(emit, events) {
+				return
+			}
+			known = current
+			proxyWatchSnapshots.add(snapshotKey, list.Metadata.ResourceVersion, known)
+			// the client resumes from the resourceVersion of the list, which has a snapshot
+			if bookmarks && len(events) > 0 && !emit(watch.Bookmark, list.bookmark()) {
+				return
+			}
+		}
+	}
+}
+
+func emitEvents(emit func(watch.EventType, json.RawMessage) bool, events []watchEvent) bool {
+	for _, event := range events {
+		if !emit(event.Type, event.Object) {
+			return false
+		}
+	}
+	return true
+}
+
+// diffWatchObjects returns the events turning the known objects into the current
+// ones, ordered by namespace/name. The deleted objects known only by the metadata
+// of a snapshot are sent as the tombstones.
+func diffWatchObjects(known, current map[string]watchObject, tombstone func(key string, obj watchObject) json.RawMessage) []watchEvent {
+	var events []watchEvent
+	for _, key := range sets.StringKeySet(current).List() {
+		prev, found := known[key]
+		switch obj := current[key]; {
+		case !found:
+			events = append(events, watchEvent{Type: watch.Added, Object: obj.raw})
+		case prev.resourceVersion != obj.resourceVersion:
+			events = append(events, watchEvent{Type: watch.Modified, Object: obj.raw})
+		}
+	}
+	for _, key := range sets.StringKeySet(known).List() {
+		if _, found := current[key]; !found {
+			obj := known[key].raw
+			if obj == nil {
+				obj = tombstone(key, known[key])
+			}
+			events = append(events, watchEvent{Type: watch.Deleted, Object: obj})
+		}
+	}
+	return events
+}
+
+const (
+	// watchSnapshotKeys bounds the lists of which the snapshots are kept
+	watchSnapshotKeys = 128
+	// watchSnapshotsPerKey bounds the snapshots kept of each list
+	watchSnapshotsPerKey = 4
+	// watchSnapshotMaxBytes bounds the memory of all the snapshots and pages
+	watchSnapshotMaxBytes = 32 << 20
+	// watchObjectOverhead is the estimated memory of an item of a snapshot other
+	// than its key, uid and resourceVersion
+	watchObjectOverhead = 64
+)
+
+// watchSnapshot is the metadata of a list served by the proxy, from which the
+// watch resuming at its resourceVersion diffs the changes
+// +k8s:openapi-gen=false
+type watchSnapshot struct {
+	resourceVersion string
+	objects         map[string]watchObject
+	size            int64
+	created         time.Time
+}
+
+func newWatchSnapshot(resourceVersion string, objects map[string]watchObject) *watchSnapshot {
+	snapshot := &watchSnapshot{resourceVersion: resourceVersion, objects: make(map[string]watchObject, len(objects)), created: time.Now()}
+	for key, obj := range objects {
+		snapshot.objects[key] = watchObject{uid: obj.uid, resourceVersion: obj.resourceVersion}
+		snapshot.size += int64(len(key)+len(obj.uid)+len(obj.resourceVersion)) + watchObjectOverhead
+	}
+	return snapshot
+}
+
+// watchSnapshots is the bounded window of the lists served to the clients, keyed
+// by SAEAPIServer, region, mapped identity, path and selectors. The watch resuming at a
+// resourceVersion outside the window is answered with 410 Gone, so that the
+// client relists instead of missing the changes.
+// +k8s:openapi-gen=false
+type watchSnapshots struct {
+	mu        sync.Mutex
+	snapshots map[string][]*watchSnapshot
+	// pages are the partial lists waiting for the page of the continue token
+	pages map[string]*watchSnapshot
+	// size is the memory of the snapshots and the pages
+	size int64
+}
+
+var proxyWatchSnapshots = &watchSnapshots{snapshots: map[string][]*watchSnapshot{}, pages: map[string]*watchSnapshot{}}
+
+func (in *proxyHandler) watchSnapshotKey(query url.Values) string {
+	selectors := url.Values{}
+	for _, key := range []string{"labelSelector", "fieldSelector"} {
+		if value := query.Get(key); value != "" {
+			selectors.Set(key, value)
+		}
+	}
+	return in.cacheKeyPrefix() + in.path + "?" + selectors.Encode()
+}
+
+// sweep drops the expired snapshots and pages, the oldest lists over the limit,
+// and then the oldest snapshots and pages until they fit into the max bytes
+func (in *watchSnapshots) sweep(now time.Time) {
+	var oldestKey string
+	var oldest time.Time
+	for key, snapshots := range in.snapshots {
+		latest := snapshots[len(snapshots)-1].created
+		if now.Sub(latest) > watchTimeout {
+			in.drop(key)
+			continue
+		}
+		if oldestKey == "" || latest.Before(oldest) {
+			oldestKey, oldest = key, latest
+		}
+	}
+	if len(in.snapshots) >= watchSnapshotKeys {
+		in.drop(oldestKey)
+	}
+	for token, page := range in.pages {
+		if now.Sub(page.created) > watchTimeout {
+			in.size -= page.size
+			delete(in.pages, token)
+		}
+	}
+	for in.size > watchSnapshotMaxBytes && in.dropOldest() {
+	}
+}
+
+// drop removes all the snapshots of the list
+func (in *watchSnapshots) drop(key string) {
+	for _, snapshot := range in.snapshots[key] {
+		in.size -= snapshot.size
+	}
+	delete(in.snapshots, key)
+}
+
+// dropOldest removes the oldest snapshot or page, false if there is none
+func (in *watchSnapshots) dropOldest() bool {
+	var oldest *watchSnapshot
+	var oldestKey, oldestToken string
+	for key, snapshots := range in.snapshots {
+		if oldest == nil || snapshots[0].created.Before(oldest.created) {
+			oldest, oldestKey = snapshots[0], key
+		}
+	}
+	for token, page := range in.pages {
+		if oldest == nil || page.created.Before(oldest.created) {
+			oldest, oldestKey, oldestToken = page, "", token
+		}
+	}
+	switch {
+	case oldest == nil:
+		return false
+	case oldestToken != "":
+		delete(in.pages, oldestToken)
+	case len(in.snapshots[oldestKey]) == 1:
+		delete(in.snapshots, oldestKey)
+	default:
+		in.snapshots[oldestKey] = in.snapshots[oldestKey][1:]
+	}
+	in.size -= oldest.size
+	return true
+}
+
+// add keeps the snapshot of the list at the resourceVersion
+func (in *watchSnapshots) add(key string, resourceVersion string, objects map[string]watchObject) {
+	if resourceVersion == "" {
+		return
+	}
+	snapshot := newWatchSnapshot(resourceVersion, objects)
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.addLocked(key, snapshot)
+}
+
+func (in *watchSnapshots) addLocked(key string, snapshot *watchSnapshot) {
+	snapshots, found := in.snapshots[key]
+	if !found {
+		in.sweep(snapshot.created)
+	}
+	for i, prev := range snapshots {
+		if prev.resourceVersion == snapshot.resourceVersion {
+			in.size -= prev.size
+			snapshots = append(snapshots[:i], snapshots[i+1:]...)
+			break
+		}
+	}
+	snapshots = append(snapshots, snapshot)
+	in.size += snapshot.size
+	if len(snapshots) > watchSnapshotsPerKey {
+		for _, dropped := range snapshots[:len(snapshots)-watchSnapshotsPerKey] {
+			in.size -= dropped.size
+		}
+		snapshots = snapshots[len(snapshots)-watchSnapshotsPerKey:]
+	}
+	in.snapshots[key] = snapshots
+	for in.size > watchSnapshotMaxBytes && in.dropOldest() {
+	}
+}
+
+// addPage keeps the page of the list, which is added as a snapshot once the last
+// page is served
+func (in *watchSnapshots) addPage(key string, continueToken string, list *watchListMeta) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	snapshot := newWatchSnapshot(list.Metadata.ResourceVersion, list.objects)
+	if continueToken != "" {
+		prev, found := in.pages[key+"#"+continueToken]
+		if !found {
+			return
+		}
+		delete(in.pages, key+"#"+continueToken)
+		in.size -= prev.size
+		for k, obj := range prev.objects {
+			snapshot.objects[k] = obj
+		}
+		snapshot.size += prev.size
+	}
+	if list.Metadata.Continue != "" {
+		in.pages[key+"#"+list.Metadata.Continue] = snapshot
+		in.size += snapshot.size
+		for in.size > watchSnapshotMaxBytes && in.dropOldest() {
+		}
+		return
+	}
+	if snapshot.resourceVersion != "" {
+		in.addLocked(key, snapshot)
+	}
+}
+
+// get returns the objects of the list at the resourceVersion
+func (in *watchSnapshots) get(key string, resourceVersion string) (map[string]watchObject, bool) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for _, snapshot := range in.snapshots[key] {
+		if snapshot.resourceVersion == resourceVersion && time.Since(snapshot.created) <= watchTimeout {
+			return snapshot.objects, true
+		}
+	}
+	return nil, false
+}
+
+// recordList keeps the metadata of the list served to the client, so that the
+// watch resuming at its resourceVersion, as informers do, diffs the changes. The
+// metadata is read as the list is streamed to the client.
+func (in *proxyHandler) recordList(req *http.Request, response *http.Response) *http.Response {
+	if req.Method != http.MethodGet || response.StatusCode != http.StatusOK {
+		return response
+	}
+	// tables and partial metadata are json as well, but not watched by the proxy
+	if mediaType, params, _ := mime.ParseMediaType(response.Header.Get("Content-Type")); mediaType != mediaTypeJSON || params["as"] != "" {
+		return response
+	}
+	infoReq := req.Clone(req.Context())
+	infoReq.URL.Path = in.path
+	if info, err := proxyRequestInfoFactory.NewRequestInfo(infoReq); err != nil || info.Verb != "list" {
+		return response
+	}
+	query := req.URL.Query()
+	key, continueToken := in.watchSnapshotKey(query), query.Get("continue")
+	response.Body = newListRecorder(response.Body, func(list *watchListMeta) {
+		if strings.HasSuffix(list.Kind, "List") {
+			proxyWatchSnapshots.addPage(key, continueToken, list)
+		}
+	})
+	return response
+}
+
+// watchListMeta is the metadata of a list and of its items, without the items
+// +k8s:openapi-gen=false
+type watchListMeta struct {
+	Kind     string
+	Metadata metav1.ListMeta
+	objects  map[string]watchObject
+}
+
+// decodeListMeta reads the metadata of the list item by item, so that only one
+// item is decoded in memory at a time
+func decodeListMeta(r io.Reader) (*watchListMeta, error) {
+	decoder := json.NewDecoder(r)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+	list := &watchListMeta{objects: map[string]watchObject{}}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch token {
+		case "kind":
+			err = decoder.Decode(&list.Kind)
+		case "metadata":
+			err = decoder.Decode(&list.Metadata)
+		case "items":
+			err = list.decodeItems(decoder)
+		default:
+			var skipped json.RawMessage
+			err = decoder.Decode(&skipped)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return list, expectDelim(decoder, '}')
+}
+
+func (in *watchListMeta) decodeItems(decoder *json.Decoder) error {
+	token, err := decoder.Token()
+	if err != nil || token == nil {
+		return err
+	}
+	if token != json.Delim('[') {
+		return fmt.Errorf("unexpected items %v", token)
+	}
+	for decoder.More() {
+		item := &struct {
+			Metadata struct {
+				Namespace       string `json:"namespace"`
+				Name            string `json:"name"`
+				UID             string `json:"uid"`
+				ResourceVersion string `json:"resourceVersion"`
+			} `json:"metadata"`
+		}{}
+		if err = decoder.Decode(item); err != nil {
+			return err
+		}
+		in.objects[item.Metadata.Namespace+"/"+item.Metadata.Name] = watchObject{uid: item.Metadata.UID, resourceVersion: item.Metadata.ResourceVersion}
+	}
+	return expectDelim(decoder, ']')
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v, got %v", delim, token)
+	}
+	return nil
+}
+
+// listRecorder tees the list streamed to the client into decodeListMeta, and
+// records the metadata once the whole list is read
+// +k8s:openapi-gen=false
+type listRecorder struct {
+	body   io.ReadCloser
+	writer *io.PipeWriter
+	done   chan struct{}
+	list   *watchListMeta
+	record func(list *watchListMeta)
+	eof    bool
+}
+
+func newListRecorder(body io.ReadCloser, record func(list *watchListMeta)) *listRecorder {
+	reader, writer := io.Pipe()
+	recorder := &listRecorder{body: body, writer: writer, done: make(chan struct{}), record: record}
+	go func() {
+		defer close(recorder.done)
+		list, err := decodeListMeta(reader)
+		if err == nil {
+			recorder.list = list
+		}
+		// the rest is drained, so that the tee never blocks
+		_, _ = io.Copy(io.Discard, reader)
+	}()
+	return recorder
+}
+
+func (in *listRecorder) Read(p []byte) (int, error) {
+	n, err := in.body.Read(p)
+	if n > 0 {
+		_, _ = in.writer.Write(p[:n])
+	}
+	if err == io.EOF && !in.eof {
+		in.eof = true
+		_ = in.writer.Close()
+		<-in.done
+		if in.list != nil {
+			in.record(in.list)
+		}
+	}
+	return n, err
+}
+
+func (in *listRecorder) Close() error {
+	if !in.eof {
+		// the list is not recorded if the client goes away before reading it all
+		_ = in.writer.CloseWithError(io.ErrUnexpectedEOF)
+	}
+	return in.body.Close()
+}
+
+// list sends the list request through the SAE VirtualServerProxy
+func (in *proxyHandler) list(req *http.Request) (*watchList, error) {
+	resp, err := in.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		status := &metav1.Status{}
+		if err = json.NewDecoder(resp.Body).Decode(status); err != nil || status.Kind != "Status" {
+			return nil, apierrors.NewGenericServerResponse(resp.StatusCode, http.MethodGet, in.groupResource(), "", "", 0, true)
+		}
+		return nil, &apierrors.StatusError{ErrStatus: *status}
+	}
+	list := &watchList{}
+	if err = json.NewDecoder(resp.Body).Decode(list); err != nil {
+		return nil, fmt.Errorf("cannot decode list for watch: %w", err)
+	}
+	if !strings.HasSuffix(list.Kind, "List") {
+		return nil, apierrors.NewMethodNotSupported(in.groupResource(), "watch")
+	}
+	return list, nil
+}
+
+func (in *proxyHandler) groupResource() schema.GroupResource {
+	return schema.GroupResource{Group: Group, Resource: SAEAPIServerResource + "/proxy"}
+}
+
+// objects indexes the items by namespace/name, with kind and apiVersion filled
+// in as the items of a list do not carry them
+func (in *watchList) objects() map[string]watchObject {
+	objects := map[string]watchObject{}
+	kind := strings.TrimSuffix(in.Kind, "List")
+	for _, item := range in.Items {
+		obj := map[string]interface{}{}
+		if err := json.Unmarshal(item, &obj); err != nil {
+			continue
+		}
+		obj["kind"], obj["apiVersion"] = kind, in.APIVersion
+		meta := &struct {
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		}{}
+		_ = json.Unmarshal(item, meta)
+		raw, _ := json.Marshal(obj)
+		objects[meta.Metadata.Namespace+"/"+meta.Metadata.Name] = watchObject{raw: raw, uid: string(meta.Metadata.UID), resourceVersion: meta.Metadata.ResourceVersion}
+	}
+	return objects
+}
+
+// bookmark is an object of the listed kind carrying only the resourceVersion of the list
+func (in *watchList) bookmark() json.RawMessage {
+	raw, _ := json.Marshal(map[string]interface{}{
+		"kind":       strings.TrimSuffix(in.Kind, "List"),
+		"apiVersion": in.APIVersion,
+		"metadata":   map[string]interface{}{"resourceVersion": in.Metadata.ResourceVersion},
+	})
+	return raw
+}
+
+// tombstone is the object of the listed kind deleted since a snapshot, carrying
+// only the metadata kept by the snapshot
+func (in *watchList) tombstone(key string, obj watchObject) json.RawMessage {
+	metadata := map[string]interface{}{"uid": obj.uid, "resourceVersion": obj.resourceVersion}
+	namespace, name, _ := strings.Cut(key, "/")
+	metadata["name"] = name
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	raw, _ := json.Marshal(map[string]interface{}{
+		"kind":       strings.TrimSuffix(in.Kind, "List"),
+		"apiVersion": in.APIVersion,
+		"metadata":   metadata,
+	})
+	return raw
+}
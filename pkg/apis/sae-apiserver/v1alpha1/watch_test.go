@@ -0,0 +1,266 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+	genericapifilters "k8s.io/apiserver/pkg/endpoints/filters"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	genericfilters "k8s.io/apiserver/pkg/server/filters"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// newTimeoutChain wraps the handler with the request timeout filters of the
+// generic apiserver, using the long-running check of the proxy
+func newTimeoutChain(handler http.Handler, timeout time.Duration) http.Handler {
+	longRunning := NewLongRunningRequestCheck(genericfilters.BasicLongRunningRequestCheck(sets.NewString("watch"), sets.NewString()))
+	chain := genericfilters.WithTimeoutForNonLongRunningRequests(handler, longRunning)
+	chain = genericapifilters.WithRequestDeadline(chain, nil, nil, longRunning, scheme.Codecs, timeout)
+	return genericapifilters.WithRequestInfo(chain, &apirequest.RequestInfoFactory{
+		APIPrefixes:          sets.NewString("api", "apis"),
+		GrouplessAPIPrefixes: sets.NewString("api"),
+	})
+}
+
+// slowHandler answers after the delay, or gives up when the request is cancelled
+func slowHandler(delay time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+		}
+	})
+}
+
+func TestLongRunningProxyRequestOutlivesRequestTimeout(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	prefix := "/apis/" + Group + "/" + Version + "/" + SAEAPIServerResource + "/sae/proxy"
+	cases := map[string]struct {
+		url  string
+		code int
+	}{
+		"watch":           {url: prefix + "/api/v1/namespaces/default/pods?watch=true", code: http.StatusOK},
+		"follow log":      {url: prefix + "/api/v1/namespaces/default/pods/web/log?follow=true", code: http.StatusOK},
+		"follow regional": {url: prefix + "/regions/cn-shanghai/api/v1/namespaces/default/pods/web/log?follow=true", code: http.StatusOK},
+		"log":             {url: prefix + "/api/v1/namespaces/default/pods/web/log", code: http.StatusGatewayTimeout},
+		"list":            {url: prefix + "/api/v1/namespaces/default/pods", code: http.StatusGatewayTimeout},
+		"watch elsewhere": {url: "/apis/" + Group + "/" + Version + "/" + SAEAPIServerResource + "/sae?watch=false", code: http.StatusGatewayTimeout},
+	}
+	chain := newTimeoutChain(slowHandler(3*timeout), timeout)
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			chain.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, c.url, nil))
+			if recorder.Code != c.code {
+				t.Fatalf("expected status %d, got %d: %s", c.code, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestDiffWatchObjects(t *testing.T) {
+	obj := func(name, rv string) watchObject {
+		return watchObject{raw: json.RawMessage(`{"name":"` + name + `","rv":"` + rv + `"}`), resourceVersion: rv}
+	}
+	list := &watchList{APIVersion: "v1", Kind: "PodList"}
+	known := map[string]watchObject{"default/a": obj("a", "1"), "default/b": obj("b", "2"), "default/c": obj("c", "3"), "default/e": {uid: "e-uid", resourceVersion: "6"}}
+	current := map[string]watchObject{"default/a": obj("a", "1"), "default/b": obj("b", "4"), "default/d": obj("d", "5")}
+	var got []string
+	for _, event := range diffWatchObjects(known, current, list.tombstone) {
+		got = append(got, string(event.Type)+" "+string(event.Object))
+	}
+	expected := []string{
+		string(watch.Modified) + ` {"name":"b","rv":"4"}`,
+		string(watch.Added) + ` {"name":"d","rv":"5"}`,
+		string(watch.Deleted) + ` {"name":"c","rv":"3"}`,
+		string(watch.Deleted) + ` {"apiVersion":"v1","kind":"Pod","metadata":{"name":"e","namespace":"default","resourceVersion":"6","uid":"e-uid"}}`,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if events := diffWatchObjects(current, current, list.tombstone); len(events) != 0 {
+		t.Fatalf("expected no events, got %v", events)
+	}
+}
+
+// listJSON is a pod list with the given items, each with a spec the snapshots do not keep
+func listJSON(rv, next string, names ...string) string {
+	var items []string
+	for _, name := range names {
+		items = append(items, `{"metadata":{"namespace":"default","name":"`+name+`","uid":"`+name+`-uid","resourceVersion":"1"},"spec":{"containers":[{"name":"app"}]}}`)
+	}
+	return `{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"` + rv + `","continue":"` + next + `"},"items":[` + strings.Join(items, ",") + `]}`
+}
+
+func TestDecodeListMeta(t *testing.T) {
+	list, err := decodeListMeta(strings.NewReader(listJSON("10", "next", "a", "b")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]watchObject{"default/a": {uid: "a-uid", resourceVersion: "1"}, "default/b": {uid: "b-uid", resourceVersion: "1"}}
+	if list.Kind != "PodList" || list.Metadata.ResourceVersion != "10" || list.Metadata.Continue != "next" || !reflect.DeepEqual(list.objects, expected) {
+		t.Fatalf("unexpected list %+v", list)
+	}
+	if list, err = decodeListMeta(strings.NewReader(`{"kind":"PodList","metadata":{},"items":null}`)); err != nil || len(list.objects) != 0 {
+		t.Fatalf("expected the empty list, got %+v, %v", list, err)
+	}
+	if _, err = decodeListMeta(strings.NewReader(`{"kind":"PodList","items":[{"metadata":`)); err == nil {
+		t.Fatalf("expected the truncated list to fail")
+	}
+}
+
+func TestWatchSnapshots(t *testing.T) {
+	snapshots := &watchSnapshots{snapshots: map[string][]*watchSnapshot{}, pages: map[string]*watchSnapshot{}}
+	page := func(rv, next string, names ...string) *watchListMeta {
+		list, err := decodeListMeta(strings.NewReader(listJSON(rv, next, names...)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return list
+	}
+	snapshots.addPage("pods", "", page("10", "next", "a"))
+	if _, found := snapshots.get("pods", "10"); found {
+		t.Fatalf("expected the partial list not to be a snapshot")
+	}
+	snapshots.addPage("pods", "next", page("10", "", "b"))
+	objects, found := snapshots.get("pods", "10")
+	if !found || len(objects) != 2 || objects["default/b"].uid != "b-uid" || objects["default/b"].raw != nil {
+		t.Fatalf("expected the metadata of both pages, got %v", objects)
+	}
+	snapshots.addPage("pods", "unknown", page("11", "", "c"))
+	if _, found = snapshots.get("pods", "11"); found {
+		t.Fatalf("expected the page of an unknown continue token not to be a snapshot")
+	}
+	snapshots.addPage("pods", "", page("", "", "c"))
+	if len(snapshots.snapshots["pods"]) != 1 {
+		t.Fatalf("expected the list without resourceVersion not to be a snapshot")
+	}
+	for i := 0; i < watchSnapshotsPerKey; i++ {
+		snapshots.add("pods", "2"+string(rune('0'+i)), map[string]watchObject{})
+	}
+	if _, found = snapshots.get("pods", "10"); found {
+		t.Fatalf("expected the oldest snapshot to be dropped")
+	}
+	if _, found = snapshots.get("pods", "20"); !found {
+		t.Fatalf("expected the recent snapshot to be kept")
+	}
+	if snapshots.size != 0 {
+		t.Fatalf("expected the size of the empty snapshots, got %d", snapshots.size)
+	}
+}
+
+func TestWatchSnapshotsMaxBytes(t *testing.T) {
+	snapshots := &watchSnapshots{snapshots: map[string][]*watchSnapshot{}, pages: map[string]*watchSnapshot{}}
+	// each snapshot takes about a third of the max bytes
+	objects := map[string]watchObject{}
+	for i := 0; len(objects)*(watchObjectOverhead+16) < watchSnapshotMaxBytes/3; i++ {
+		objects[fmt.Sprintf("default/%08d", i)] = watchObject{resourceVersion: "1"}
+	}
+	for _, key := range []string{"a", "b", "c", "d"} {
+		snapshots.add(key, "1", objects)
+		time.Sleep(time.Millisecond)
+	}
+	if snapshots.size > watchSnapshotMaxBytes {
+		t.Fatalf("expected at most %d bytes, got %d", watchSnapshotMaxBytes, snapshots.size)
+	}
+	if _, found := snapshots.get("a", "1"); found {
+		t.Fatalf("expected the oldest snapshot to be dropped")
+	}
+	if _, found := snapshots.get("d", "1"); !found {
+		t.Fatalf("expected the recent snapshot to be kept")
+	}
+}
+
+func TestRecordList(t *testing.T) {
+	defer func(snapshots *watchSnapshots) { proxyWatchSnapshots = snapshots }(proxyWatchSnapshots)
+	handler := &proxyHandler{apiserver: &SAEAPIServer{ObjectMeta: metav1.ObjectMeta{Name: "sae"}}, region: "cn-hangzhou", path: "/api/v1/namespaces/default/pods"}
+	cases := map[string]struct {
+		path        string
+		contentType string
+		body        string
+		readAll     bool
+		recorded    bool
+	}{
+		"list":         {contentType: "application/json", body: listJSON("10", "", "a"), readAll: true, recorded: true},
+		"not read":     {contentType: "application/json", body: listJSON("10", "", "a")},
+		"table":        {contentType: "application/json;as=Table;v=v1;g=meta.k8s.io", body: listJSON("10", "", "a"), readAll: true},
+		"not a list":   {contentType: "application/json", body: `{"kind":"Status","metadata":{"resourceVersion":"10"}}`, readAll: true},
+		"get":          {path: "/api/v1/namespaces/default/pods/a", contentType: "application/json", body: listJSON("10", "", "a"), readAll: true},
+		"protobuf":     {contentType: "application/vnd.kubernetes.protobuf", body: listJSON("10", "", "a"), readAll: true},
+		"invalid list": {contentType: "application/json", body: `{"kind":"PodList","items":[`, readAll: true},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			proxyWatchSnapshots = &watchSnapshots{snapshots: map[string][]*watchSnapshot{}, pages: map[string]*watchSnapshot{}}
+			h := *handler
+			if c.path != "" {
+				h.path = c.path
+			}
+			response := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": []string{c.contentType}}, Body: io.NopCloser(strings.NewReader(c.body))}
+			response = h.recordList(httptest.NewRequest(http.MethodGet, h.path, nil), response)
+			if c.readAll {
+				data, err := io.ReadAll(response.Body)
+				if err != nil || string(data) != c.body {
+					t.Fatalf("expected the body to be passed through, got %s, %v", data, err)
+				}
+			} else {
+				_, _ = response.Body.Read(make([]byte, 8))
+			}
+			_ = response.Body.Close()
+			if _, found := proxyWatchSnapshots.get(h.watchSnapshotKey(url.Values{}), "10"); found != c.recorded {
+				t.Fatalf("expected recorded %t, got %t", c.recorded, found)
+			}
+		})
+	}
+}
+
+// errorResponder records the error of the handler
+type errorResponder struct {
+	err error
+}
+
+func (in *errorResponder) Object(int, runtime.Object) {}
+
+func (in *errorResponder) Error(err error) {
+	in.err = err
+}
+
+func TestServeWatchUnknownResourceVersion(t *testing.T) {
+	responder := &errorResponder{}
+	handler := &proxyHandler{apiserver: &SAEAPIServer{ObjectMeta: metav1.ObjectMeta{Name: "sae"}}, path: "/api/v1/pods", responder: responder}
+	recorder := httptest.NewRecorder()
+	handler.serveWatch(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/pods?watch=true&resourceVersion=42", nil))
+	if !apierrors.IsResourceExpired(responder.err) {
+		t.Fatalf("expected 410 Gone, got %v", responder.err)
+	}
+}
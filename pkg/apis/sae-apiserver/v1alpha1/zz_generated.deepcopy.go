@@ -22,6 +22,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -30,7 +31,8 @@ func (in *SAEAPIServer) DeepCopyInto(out *SAEAPIServer) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServer.
@@ -54,6 +56,26 @@ func (in *SAEAPIServer) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SAEAPIServerCredential) DeepCopyInto(out *SAEAPIServerCredential) {
 	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(SAEAPIServerSecretReference)
+		**out = **in
+	}
+	if in.STS != nil {
+		in, out := &in.STS, &out.STS
+		*out = new(SAEAPIServerSTSCredential)
+		**out = **in
+	}
+	if in.OIDC != nil {
+		in, out := &in.OIDC, &out.OIDC
+		*out = new(SAEAPIServerOIDCCredential)
+		**out = **in
+	}
+	if in.CredentialRef != nil {
+		in, out := &in.CredentialRef, &out.CredentialRef
+		*out = new(SAECredentialReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServerCredential.
@@ -66,6 +88,124 @@ func (in *SAEAPIServerCredential) DeepCopy() *SAEAPIServerCredential {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAEAPIServerHeaderPolicy) DeepCopyInto(out *SAEAPIServerHeaderPolicy) {
+	*out = *in
+	if in.Request != nil {
+		in, out := &in.Request, &out.Request
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Response != nil {
+		in, out := &in.Response, &out.Response
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServerHeaderPolicy.
+func (in *SAEAPIServerHeaderPolicy) DeepCopy() *SAEAPIServerHeaderPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(SAEAPIServerHeaderPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAEAPIServerHistory) DeepCopyInto(out *SAEAPIServerHistory) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	if in.Entries != nil {
+		in, out := &in.Entries, &out.Entries
+		*out = make([]SAEAPIServerHistoryEntry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServerHistory.
+func (in *SAEAPIServerHistory) DeepCopy() *SAEAPIServerHistory {
+	if in == nil {
+		return nil
+	}
+	out := new(SAEAPIServerHistory)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SAEAPIServerHistory) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAEAPIServerHistoryEntry) DeepCopyInto(out *SAEAPIServerHistoryEntry) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServerHistoryEntry.
+func (in *SAEAPIServerHistoryEntry) DeepCopy() *SAEAPIServerHistoryEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(SAEAPIServerHistoryEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAEAPIServerHistoryStorage) DeepCopyInto(out *SAEAPIServerHistoryStorage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServerHistoryStorage.
+func (in *SAEAPIServerHistoryStorage) DeepCopy() *SAEAPIServerHistoryStorage {
+	if in == nil {
+		return nil
+	}
+	out := new(SAEAPIServerHistoryStorage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAEAPIServerIdentityMapping) DeepCopyInto(out *SAEAPIServerIdentityMapping) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CredentialRef != nil {
+		in, out := &in.CredentialRef, &out.CredentialRef
+		*out = new(SAECredentialReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServerIdentityMapping.
+func (in *SAEAPIServerIdentityMapping) DeepCopy() *SAEAPIServerIdentityMapping {
+	if in == nil {
+		return nil
+	}
+	out := new(SAEAPIServerIdentityMapping)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SAEAPIServerList) DeepCopyInto(out *SAEAPIServerList) {
 	*out = *in
@@ -98,6 +238,21 @@ func (in *SAEAPIServerList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAEAPIServerOIDCCredential) DeepCopyInto(out *SAEAPIServerOIDCCredential) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServerOIDCCredential.
+func (in *SAEAPIServerOIDCCredential) DeepCopy() *SAEAPIServerOIDCCredential {
+	if in == nil {
+		return nil
+	}
+	out := new(SAEAPIServerOIDCCredential)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SAEAPIServerProxy) DeepCopyInto(out *SAEAPIServerProxy) {
 	*out = *in
@@ -113,6 +268,43 @@ func (in *SAEAPIServerProxy) DeepCopy() *SAEAPIServerProxy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAEAPIServerProxyConfig) DeepCopyInto(out *SAEAPIServerProxyConfig) {
+	*out = *in
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(SAEAPIServerRetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(SAEAPIServerRateLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SupportedResources != nil {
+		in, out := &in.SupportedResources, &out.SupportedResources
+		*out = make([]SAEAPIServerSupportedResource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = new(SAEAPIServerHeaderPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServerProxyConfig.
+func (in *SAEAPIServerProxyConfig) DeepCopy() *SAEAPIServerProxyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SAEAPIServerProxyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SAEAPIServerProxyOptions) DeepCopyInto(out *SAEAPIServerProxyOptions) {
 	*out = *in
@@ -137,10 +329,122 @@ func (in *SAEAPIServerProxyOptions) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAEAPIServerRateLimit) DeepCopyInto(out *SAEAPIServerRateLimit) {
+	*out = *in
+	if in.QPS != nil {
+		in, out := &in.QPS, &out.QPS
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Burst != nil {
+		in, out := &in.Burst, &out.Burst
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PerUser != nil {
+		in, out := &in.PerUser, &out.PerUser
+		*out = new(bool)
+		**out = **in
+	}
+	if in.QueueTimeout != nil {
+		in, out := &in.QueueTimeout, &out.QueueTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServerRateLimit.
+func (in *SAEAPIServerRateLimit) DeepCopy() *SAEAPIServerRateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(SAEAPIServerRateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAEAPIServerRetryPolicy) DeepCopyInto(out *SAEAPIServerRetryPolicy) {
+	*out = *in
+	if in.MaxRetries != nil {
+		in, out := &in.MaxRetries, &out.MaxRetries
+		*out = new(int32)
+		**out = **in
+	}
+	if in.InitialBackoff != nil {
+		in, out := &in.InitialBackoff, &out.InitialBackoff
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxBackoff != nil {
+		in, out := &in.MaxBackoff, &out.MaxBackoff
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServerRetryPolicy.
+func (in *SAEAPIServerRetryPolicy) DeepCopy() *SAEAPIServerRetryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(SAEAPIServerRetryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAEAPIServerSTSCredential) DeepCopyInto(out *SAEAPIServerSTSCredential) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServerSTSCredential.
+func (in *SAEAPIServerSTSCredential) DeepCopy() *SAEAPIServerSTSCredential {
+	if in == nil {
+		return nil
+	}
+	out := new(SAEAPIServerSTSCredential)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAEAPIServerSecretReference) DeepCopyInto(out *SAEAPIServerSecretReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServerSecretReference.
+func (in *SAEAPIServerSecretReference) DeepCopy() *SAEAPIServerSecretReference {
+	if in == nil {
+		return nil
+	}
+	out := new(SAEAPIServerSecretReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SAEAPIServerSpec) DeepCopyInto(out *SAEAPIServerSpec) {
 	*out = *in
-	out.SAEAPIServerCredential = in.SAEAPIServerCredential
+	in.SAEAPIServerCredential.DeepCopyInto(&out.SAEAPIServerCredential)
+	if in.Regions != nil {
+		in, out := &in.Regions, &out.Regions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Proxy != nil {
+		in, out := &in.Proxy, &out.Proxy
+		*out = new(SAEAPIServerProxyConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IdentityMappings != nil {
+		in, out := &in.IdentityMappings, &out.IdentityMappings
+		*out = make([]SAEAPIServerIdentityMapping, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServerSpec.
@@ -152,3 +456,133 @@ func (in *SAEAPIServerSpec) DeepCopy() *SAEAPIServerSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAEAPIServerStatus) DeepCopyInto(out *SAEAPIServerStatus) {
+	*out = *in
+	if in.RegionProxyEndpoints != nil {
+		in, out := &in.RegionProxyEndpoints, &out.RegionProxyEndpoints
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServerStatus.
+func (in *SAEAPIServerStatus) DeepCopy() *SAEAPIServerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SAEAPIServerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAEAPIServerSupportedResource) DeepCopyInto(out *SAEAPIServerSupportedResource) {
+	*out = *in
+	if in.Verbs != nil {
+		in, out := &in.Verbs, &out.Verbs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAEAPIServerSupportedResource.
+func (in *SAEAPIServerSupportedResource) DeepCopy() *SAEAPIServerSupportedResource {
+	if in == nil {
+		return nil
+	}
+	out := new(SAEAPIServerSupportedResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAECredential) DeepCopyInto(out *SAECredential) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAECredential.
+func (in *SAECredential) DeepCopy() *SAECredential {
+	if in == nil {
+		return nil
+	}
+	out := new(SAECredential)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SAECredential) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAECredentialList) DeepCopyInto(out *SAECredentialList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SAECredential, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAECredentialList.
+func (in *SAECredentialList) DeepCopy() *SAECredentialList {
+	if in == nil {
+		return nil
+	}
+	out := new(SAECredentialList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SAECredentialList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAECredentialReference) DeepCopyInto(out *SAECredentialReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAECredentialReference.
+func (in *SAECredentialReference) DeepCopy() *SAECredentialReference {
+	if in == nil {
+		return nil
+	}
+	out := new(SAECredentialReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SAECredentialSpec) DeepCopyInto(out *SAECredentialSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SAECredentialSpec.
+func (in *SAECredentialSpec) DeepCopy() *SAECredentialSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SAECredentialSpec)
+	in.DeepCopyInto(out)
+	return out
+}
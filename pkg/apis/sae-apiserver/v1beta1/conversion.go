@@ -0,0 +1,241 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/kubevela-contrib/sae-apiserver-proxy/pkg/apis/sae-apiserver/v1alpha1"
+)
+
+// RegisterConversions registers the conversions between v1beta1 and v1alpha1.
+// The conversions of SAEAPIServer are also registered by the apiserver-runtime
+// builder through MultiVersionObject, the list ones are not.
+func RegisterConversions(s *runtime.Scheme) error {
+	if err := s.AddConversionFunc((*SAEAPIServer)(nil), (*v1alpha1.SAEAPIServer)(nil), func(a, b interface{}, _ conversion.Scope) error {
+		return a.(*SAEAPIServer).ConvertToStorageVersion(b.(*v1alpha1.SAEAPIServer))
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1alpha1.SAEAPIServer)(nil), (*SAEAPIServer)(nil), func(a, b interface{}, _ conversion.Scope) error {
+		return b.(*SAEAPIServer).ConvertFromStorageVersion(a.(*v1alpha1.SAEAPIServer))
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*SAEAPIServerList)(nil), (*v1alpha1.SAEAPIServerList)(nil), func(a, b interface{}, _ conversion.Scope) error {
+		return Convert_v1beta1_SAEAPIServerList_To_v1alpha1_SAEAPIServerList(a.(*SAEAPIServerList), b.(*v1alpha1.SAEAPIServerList))
+	}); err != nil {
+		return err
+	}
+	return s.AddConversionFunc((*v1alpha1.SAEAPIServerList)(nil), (*SAEAPIServerList)(nil), func(a, b interface{}, _ conversion.Scope) error {
+		return Convert_v1alpha1_SAEAPIServerList_To_v1beta1_SAEAPIServerList(a.(*v1alpha1.SAEAPIServerList), b.(*SAEAPIServerList))
+	})
+}
+
+func (in *SAEAPIServer) ConvertToStorageVersion(storageObj runtime.Object) error {
+	out, ok := storageObj.(*v1alpha1.SAEAPIServer)
+	if !ok {
+		return fmt.Errorf("unexpected storage object %T", storageObj)
+	}
+	Convert_v1beta1_SAEAPIServer_To_v1alpha1_SAEAPIServer(in, out)
+	return nil
+}
+
+func (in *SAEAPIServer) ConvertFromStorageVersion(storageObj runtime.Object) error {
+	obj, ok := storageObj.(*v1alpha1.SAEAPIServer)
+	if !ok {
+		return fmt.Errorf("unexpected storage object %T", storageObj)
+	}
+	Convert_v1alpha1_SAEAPIServer_To_v1beta1_SAEAPIServer(obj, in)
+	return nil
+}
+
+// Convert_v1beta1_SAEAPIServer_To_v1alpha1_SAEAPIServer converts v1beta1 SAEAPIServer to v1alpha1
+func Convert_v1beta1_SAEAPIServer_To_v1alpha1_SAEAPIServer(in *SAEAPIServer, out *v1alpha1.SAEAPIServer) {
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = v1alpha1.SAEAPIServerSpec{
+		Region:   in.Spec.Endpoint.Region,
+		Endpoint: in.Spec.Endpoint.OpenAPIEndpoint,
+		Regions:  append([]string(nil), in.Spec.Endpoint.Regions...),
+	}
+	cred := in.Spec.Credential
+	if cred.Inline != nil {
+		out.Spec.AccessKeyId = cred.Inline.AccessKeyId
+		out.Spec.AccessKeySecret = cred.Inline.AccessKeySecret
+	}
+	if cred.SecretRef != nil {
+		ref := v1alpha1.SAEAPIServerSecretReference(*cred.SecretRef)
+		out.Spec.SecretRef = &ref
+	}
+	if cred.STS != nil {
+		sts := v1alpha1.SAEAPIServerSTSCredential(*cred.STS)
+		out.Spec.STS = &sts
+	}
+	if cred.OIDC != nil {
+		oidc := v1alpha1.SAEAPIServerOIDCCredential(*cred.OIDC)
+		out.Spec.OIDC = &oidc
+	}
+	if cred.CredentialRef != nil {
+		ref := v1alpha1.SAECredentialReference(*cred.CredentialRef)
+		out.Spec.CredentialRef = &ref
+	}
+	out.Spec.Proxy = convertProxyConfigToV1alpha1(in.Spec.Proxy)
+	out.Spec.IdentityMappings = convertIdentityMappingsToV1alpha1(in.Spec.IdentityMappings)
+	in.Status.DeepCopyInto((*SAEAPIServerStatus)(&out.Status))
+}
+
+// Convert_v1alpha1_SAEAPIServer_To_v1beta1_SAEAPIServer converts v1alpha1 SAEAPIServer to v1beta1
+func Convert_v1alpha1_SAEAPIServer_To_v1beta1_SAEAPIServer(in *v1alpha1.SAEAPIServer, out *SAEAPIServer) {
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = SAEAPIServerSpec{
+		Endpoint: SAEAPIServerEndpoint{
+			Region:          in.Spec.Region,
+			OpenAPIEndpoint: in.Spec.Endpoint,
+			Regions:         append([]string(nil), in.Spec.Regions...),
+		},
+	}
+	if in.Spec.AccessKeyId != "" || in.Spec.AccessKeySecret != "" {
+		out.Spec.Credential.Inline = &SAEAPIServerInlineCredential{
+			AccessKeyId:     in.Spec.AccessKeyId,
+			AccessKeySecret: in.Spec.AccessKeySecret,
+		}
+	}
+	if in.Spec.SecretRef != nil {
+		ref := SAEAPIServerSecretReference(*in.Spec.SecretRef)
+		out.Spec.Credential.SecretRef = &ref
+	}
+	if in.Spec.STS != nil {
+		sts := SAEAPIServerSTSCredential(*in.Spec.STS)
+		out.Spec.Credential.STS = &sts
+	}
+	if in.Spec.OIDC != nil {
+		oidc := SAEAPIServerOIDCCredential(*in.Spec.OIDC)
+		out.Spec.Credential.OIDC = &oidc
+	}
+	if in.Spec.CredentialRef != nil {
+		ref := SAECredentialReference(*in.Spec.CredentialRef)
+		out.Spec.Credential.CredentialRef = &ref
+	}
+	out.Spec.Proxy = convertProxyConfigFromV1alpha1(in.Spec.Proxy)
+	out.Spec.IdentityMappings = convertIdentityMappingsFromV1alpha1(in.Spec.IdentityMappings)
+	in.Status.DeepCopyInto((*v1alpha1.SAEAPIServerStatus)(&out.Status))
+}
+
+func convertProxyConfigToV1alpha1(in *SAEAPIServerProxyConfig) *v1alpha1.SAEAPIServerProxyConfig {
+	if in == nil {
+		return nil
+	}
+	out := &v1alpha1.SAEAPIServerProxyConfig{
+		RequestBodyEncoding: v1alpha1.BodyEncoding(in.RequestBodyEncoding),
+		CompressRequests:    in.CompressRequests,
+	}
+	if in.Retry != nil {
+		out.Retry = (*v1alpha1.SAEAPIServerRetryPolicy)(in.Retry.DeepCopy())
+	}
+	if in.RateLimit != nil {
+		out.RateLimit = (*v1alpha1.SAEAPIServerRateLimit)(in.RateLimit.DeepCopy())
+	}
+	for _, r := range in.SupportedResources {
+		out.SupportedResources = append(out.SupportedResources, v1alpha1.SAEAPIServerSupportedResource(*r.DeepCopy()))
+	}
+	if in.Headers != nil {
+		out.Headers = (*v1alpha1.SAEAPIServerHeaderPolicy)(in.Headers.DeepCopy())
+	}
+	return out
+}
+
+func convertProxyConfigFromV1alpha1(in *v1alpha1.SAEAPIServerProxyConfig) *SAEAPIServerProxyConfig {
+	if in == nil {
+		return nil
+	}
+	out := &SAEAPIServerProxyConfig{
+		RequestBodyEncoding: string(in.RequestBodyEncoding),
+		CompressRequests:    in.CompressRequests,
+	}
+	if in.Retry != nil {
+		out.Retry = (*SAEAPIServerRetryPolicy)(in.Retry.DeepCopy())
+	}
+	if in.RateLimit != nil {
+		out.RateLimit = (*SAEAPIServerRateLimit)(in.RateLimit.DeepCopy())
+	}
+	for _, r := range in.SupportedResources {
+		out.SupportedResources = append(out.SupportedResources, SAEAPIServerSupportedResource(*r.DeepCopy()))
+	}
+	if in.Headers != nil {
+		out.Headers = (*SAEAPIServerHeaderPolicy)(in.Headers.DeepCopy())
+	}
+	return out
+}
+
+func convertIdentityMappingsToV1alpha1(in []SAEAPIServerIdentityMapping) []v1alpha1.SAEAPIServerIdentityMapping {
+	var out []v1alpha1.SAEAPIServerIdentityMapping
+	for _, m := range in {
+		mapping := v1alpha1.SAEAPIServerIdentityMapping{
+			Users:   append([]string(nil), m.Users...),
+			Groups:  append([]string(nil), m.Groups...),
+			RoleArn: m.RoleArn,
+			Policy:  m.Policy,
+		}
+		if m.CredentialRef != nil {
+			ref := v1alpha1.SAECredentialReference(*m.CredentialRef)
+			mapping.CredentialRef = &ref
+		}
+		out = append(out, mapping)
+	}
+	return out
+}
+
+func convertIdentityMappingsFromV1alpha1(in []v1alpha1.SAEAPIServerIdentityMapping) []SAEAPIServerIdentityMapping {
+	var out []SAEAPIServerIdentityMapping
+	for _, m := range in {
+		mapping := SAEAPIServerIdentityMapping{
+			Users:   append([]string(nil), m.Users...),
+			Groups:  append([]string(nil), m.Groups...),
+			RoleArn: m.RoleArn,
+			Policy:  m.Policy,
+		}
+		if m.CredentialRef != nil {
+			ref := SAECredentialReference(*m.CredentialRef)
+			mapping.CredentialRef = &ref
+		}
+		out = append(out, mapping)
+	}
+	return out
+}
+
+// Convert_v1beta1_SAEAPIServerList_To_v1alpha1_SAEAPIServerList converts v1beta1 SAEAPIServerList to v1alpha1
+func Convert_v1beta1_SAEAPIServerList_To_v1alpha1_SAEAPIServerList(in *SAEAPIServerList, out *v1alpha1.SAEAPIServerList) error {
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	out.Items = make([]v1alpha1.SAEAPIServer, len(in.Items))
+	for i := range in.Items {
+		Convert_v1beta1_SAEAPIServer_To_v1alpha1_SAEAPIServer(&in.Items[i], &out.Items[i])
+	}
+	return nil
+}
+
+// Convert_v1alpha1_SAEAPIServerList_To_v1beta1_SAEAPIServerList converts v1alpha1 SAEAPIServerList to v1beta1
+func Convert_v1alpha1_SAEAPIServerList_To_v1beta1_SAEAPIServerList(in *v1alpha1.SAEAPIServerList, out *SAEAPIServerList) error {
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	out.Items = make([]SAEAPIServer, len(in.Items))
+	for i := range in.Items {
+		Convert_v1alpha1_SAEAPIServer_To_v1beta1_SAEAPIServer(&in.Items[i], &out.Items[i])
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+
+	"github.com/kubevela-contrib/sae-apiserver-proxy/pkg/apis/sae-apiserver/v1alpha1"
+)
+
+func newTestSAEAPIServer() *SAEAPIServer {
+	return &SAEAPIServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "test",
+			Labels:          map[string]string{"env": "prod"},
+			ResourceVersion: "42",
+		},
+		Spec: SAEAPIServerSpec{
+			Endpoint: SAEAPIServerEndpoint{
+				Region:          "cn-beijing",
+				OpenAPIEndpoint: "sae.example.com",
+				Regions:         []string{"cn-shanghai"},
+			},
+			Credential: SAEAPIServerCredential{
+				Inline:        &SAEAPIServerInlineCredential{AccessKeyId: "ak", AccessKeySecret: "sk"},
+				SecretRef:     &SAEAPIServerSecretReference{Namespace: "ns", Name: "aksk", AccessKeyIdKey: "id"},
+				STS:           &SAEAPIServerSTSCredential{AccessKeyId: "ak", AccessKeySecret: "sk", RoleArn: "acs:ram::1:role/sae"},
+				OIDC:          &SAEAPIServerOIDCCredential{RoleArn: "acs:ram::1:role/sae", OIDCProviderArn: "acs:ram::1:oidc-provider/ack"},
+				CredentialRef: &SAECredentialReference{Name: "shared"},
+			},
+			Proxy: &SAEAPIServerProxyConfig{
+				RequestBodyEncoding: "Base64",
+				CompressRequests:    true,
+				Retry: &SAEAPIServerRetryPolicy{
+					MaxRetries:     pointer.Int32(3),
+					InitialBackoff: &metav1.Duration{Duration: time.Second},
+					MaxBackoff:     &metav1.Duration{Duration: time.Minute},
+				},
+				RateLimit: &SAEAPIServerRateLimit{
+					QPS:          pointer.Int32(10),
+					Burst:        pointer.Int32(20),
+					PerUser:      pointer.Bool(true),
+					QueueTimeout: &metav1.Duration{Duration: time.Second},
+				},
+				SupportedResources: []SAEAPIServerSupportedResource{
+					{Resource: "pods/log"},
+					{Group: "apps", Resource: "deployments", Verbs: []string{"get", "list"}},
+				},
+				Headers: &SAEAPIServerHeaderPolicy{Request: []string{"X-Trace"}, Response: []string{"X-Request-Id"}},
+			},
+			IdentityMappings: []SAEAPIServerIdentityMapping{
+				{Users: []string{"alice"}, CredentialRef: &SAECredentialReference{Name: "alice"}},
+				{Groups: []string{"dev"}, RoleArn: "acs:ram::1:role/dev", Policy: "{}"},
+			},
+		},
+		Status: SAEAPIServerStatus{
+			ProxyEndpoint:        "https://proxy/",
+			CredentialType:       "ServiceAccountToken",
+			RegionProxyEndpoints: map[string]string{"cn-shanghai": "https://proxy/regions/cn-shanghai/"},
+		},
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	in := newTestSAEAPIServer()
+	storage := &v1alpha1.SAEAPIServer{}
+	if err := in.ConvertToStorageVersion(storage); err != nil {
+		t.Fatal(err)
+	}
+	out := &SAEAPIServer{}
+	if err := out.ConvertFromStorageVersion(storage); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("expected %+v, got %+v", in, out)
+	}
+
+	back := &v1alpha1.SAEAPIServer{}
+	Convert_v1beta1_SAEAPIServer_To_v1alpha1_SAEAPIServer(out, back)
+	if !reflect.DeepEqual(storage, back) {
+		t.Fatalf("expected %+v, got %+v", storage, back)
+	}
+}
+
+func TestConvertEmpty(t *testing.T) {
+	in := &SAEAPIServer{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	storage := &v1alpha1.SAEAPIServer{}
+	Convert_v1beta1_SAEAPIServer_To_v1alpha1_SAEAPIServer(in, storage)
+	if storage.Spec.Proxy != nil || storage.Spec.IdentityMappings != nil || storage.Spec.GetType() != "" {
+		t.Fatalf("expected an empty spec, got %+v", storage.Spec)
+	}
+	out := &SAEAPIServer{}
+	Convert_v1alpha1_SAEAPIServer_To_v1beta1_SAEAPIServer(storage, out)
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("expected %+v, got %+v", in, out)
+	}
+}
+
+func TestConvertDoesNotAlias(t *testing.T) {
+	in := newTestSAEAPIServer()
+	storage := &v1alpha1.SAEAPIServer{}
+	Convert_v1beta1_SAEAPIServer_To_v1alpha1_SAEAPIServer(in, storage)
+	storage.Spec.Regions[0] = "changed"
+	storage.Spec.SecretRef.Name = "changed"
+	storage.Spec.Proxy.SupportedResources[1].Verbs[0] = "changed"
+	storage.Spec.IdentityMappings[0].CredentialRef.Name = "changed"
+	if !reflect.DeepEqual(in, newTestSAEAPIServer()) {
+		t.Fatalf("the conversion shares memory with the source: %+v", in)
+	}
+}
+
+func TestConvertList(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := RegisterConversions(scheme); err != nil {
+		t.Fatal(err)
+	}
+	in := &SAEAPIServerList{
+		ListMeta: metav1.ListMeta{ResourceVersion: "42"},
+		Items:    []SAEAPIServer{*newTestSAEAPIServer()},
+	}
+	storage := &v1alpha1.SAEAPIServerList{}
+	if err := scheme.Convert(in, storage, nil); err != nil {
+		t.Fatal(err)
+	}
+	if storage.ResourceVersion != "42" || len(storage.Items) != 1 || storage.Items[0].Spec.Region != "cn-beijing" {
+		t.Fatalf("unexpected list %+v", storage)
+	}
+	out := &SAEAPIServerList{}
+	if err := scheme.Convert(storage, out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("expected %+v, got %+v", in, out)
+	}
+}
+
+func TestConvertUnexpectedStorageObject(t *testing.T) {
+	in := &SAEAPIServer{}
+	if err := in.ConvertToStorageVersion(&v1alpha1.SAECredential{}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := in.ConvertFromStorageVersion(&v1alpha1.SAECredential{}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
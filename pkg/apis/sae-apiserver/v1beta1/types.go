@@ -0,0 +1,286 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/apiserver-runtime/pkg/builder/resource"
+
+	"github.com/kubevela-contrib/sae-apiserver-proxy/pkg/apis/sae-apiserver/v1alpha1"
+)
+
+var _ resource.Object = &SAEAPIServer{}
+var _ resource.MultiVersionObject = &SAEAPIServer{}
+
+// SAEAPIServer registers a SAE APIServer, which is served as a cluster in cluster-gateway
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type SAEAPIServer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the credential and endpoint of the SAE APIServer
+	Spec SAEAPIServerSpec `json:"spec,omitempty"`
+	// Status is the observed state of the SAE APIServer
+	Status SAEAPIServerStatus `json:"status,omitempty"`
+}
+
+func (in *SAEAPIServer) GetObjectMeta() *metav1.ObjectMeta {
+	return &in.ObjectMeta
+}
+
+func (in *SAEAPIServer) NamespaceScoped() bool {
+	return false
+}
+
+func (in *SAEAPIServer) New() runtime.Object {
+	return &SAEAPIServer{}
+}
+
+func (in *SAEAPIServer) NewList() runtime.Object {
+	return &SAEAPIServerList{}
+}
+
+func (in *SAEAPIServer) GetGroupVersionResource() schema.GroupVersionResource {
+	return GroupVersion.WithResource(SAEAPIServerResource)
+}
+
+// IsStorageVersion returns false, v1alpha1 is the storage version
+func (in *SAEAPIServer) IsStorageVersion() bool {
+	return false
+}
+
+func (in *SAEAPIServer) NewStorageVersionObject() runtime.Object {
+	return &v1alpha1.SAEAPIServer{}
+}
+
+// SAEAPIServerList is a list of SAEAPIServer
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type SAEAPIServerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	// Items is the list of SAEAPIServer
+	Items []SAEAPIServer `json:"items"`
+}
+
+// SAEAPIServerSpec is the specification of SAEAPIServer
+type SAEAPIServerSpec struct {
+	// Credential used to access the SAE OpenAPI
+	Credential SAEAPIServerCredential `json:"credential"`
+	// IdentityMappings picks the credential by the caller, the first matched
+	// mapping applies and the credential of the SAEAPIServer is the default
+	// +listType=atomic
+	IdentityMappings []SAEAPIServerIdentityMapping `json:"identityMappings,omitempty"`
+	// Endpoint locates the SAE OpenAPI
+	Endpoint SAEAPIServerEndpoint `json:"endpoint,omitempty"`
+	// Proxy tunes how the requests are proxied through SAE
+	Proxy *SAEAPIServerProxyConfig `json:"proxy,omitempty"`
+}
+
+// SAEAPIServerIdentityMapping maps the users and groups of the hub to the
+// credential used to access SAE on their behalf
+type SAEAPIServerIdentityMapping struct {
+	// Users are the names of the matched users
+	// +listType=set
+	Users []string `json:"users,omitempty"`
+	// Groups are the names of the matched groups
+	// +listType=set
+	Groups []string `json:"groups,omitempty"`
+	// CredentialRef uses the AK/SK of the SAECredential for the matched callers
+	CredentialRef *SAECredentialReference `json:"credentialRef,omitempty"`
+	// RoleArn is the RAM role assumed for the matched callers, with the AK/SK of
+	// the credentialRef, or with the credential of the SAEAPIServer if not set
+	RoleArn string `json:"roleArn,omitempty"`
+	// Policy further restricts the permissions of the assumed role, not
+	// supported by OIDC
+	Policy string `json:"policy,omitempty"`
+}
+
+// SAEAPIServerProxyConfig tunes how the requests are proxied through the SAE VirtualServerProxy
+type SAEAPIServerProxyConfig struct {
+	// RequestBodyEncoding is how the request body is put into the SAE envelope,
+	// one of Text, Auto and Base64. Text, the default, puts the body into the
+	// content as is, which is the envelope documented by the SAE VirtualServerProxy
+	// API. Auto encodes the body in base64 when it is not valid UTF-8 text, and
+	// Base64 always does, marked by the contentEncoding field of the envelope,
+	// which is not documented by SAE. They must only be set if the SAE endpoint
+	// decodes that field.
+	RequestBodyEncoding string `json:"requestBodyEncoding,omitempty"`
+	// CompressRequests sends the request bodies larger than the compression
+	// threshold of the proxy compressed by gzip, marked by the contentEncoding
+	// field of the envelope, unless the encoding is set to Text. Like the Auto
+	// and Base64 encodings, it must only be set if the SAE endpoint decodes that
+	// field.
+	CompressRequests bool `json:"compressRequests,omitempty"`
+	// Retry limits the retries of the idempotent requests on throttling and
+	// transient errors of SAE
+	Retry *SAEAPIServerRetryPolicy `json:"retry,omitempty"`
+	// RateLimit limits the requests proxied to SAE, which has QPS limits per account
+	RateLimit *SAEAPIServerRateLimit `json:"rateLimit,omitempty"`
+	// SupportedResources limits the resources advertised in the discovery and
+	// served through the proxy, all resources are allowed if empty
+	// +listType=atomic
+	SupportedResources []SAEAPIServerSupportedResource `json:"supportedResources,omitempty"`
+	// Headers extends the headers passed between the clients and SAE
+	Headers *SAEAPIServerHeaderPolicy `json:"headers,omitempty"`
+}
+
+// SAEAPIServerHeaderPolicy lists the headers passed on top of the default
+// allow-lists. Credentials, impersonation and hop-by-hop headers are never passed.
+type SAEAPIServerHeaderPolicy struct {
+	// Request are the extra request headers forwarded to SAE
+	// +listType=set
+	Request []string `json:"request,omitempty"`
+	// Response are the extra response headers of SAE returned to the clients
+	// +listType=set
+	Response []string `json:"response,omitempty"`
+}
+
+// SAEAPIServerSupportedResource is a resource supported by the SAE APIServer
+type SAEAPIServerSupportedResource struct {
+	// Group of the resource, empty for the core group
+	Group string `json:"group,omitempty"`
+	// Resource is the plural name of the resource, such as deployments, which
+	// covers the subresources as well, or a subresource only, such as pods/log
+	Resource string `json:"resource"`
+	// Verbs limits the verbs of the resource, such as get and list, all verbs
+	// are allowed if empty
+	// +listType=set
+	Verbs []string `json:"verbs,omitempty"`
+}
+
+// SAEAPIServerRetryPolicy limits the retries with jittered exponential backoff
+type SAEAPIServerRetryPolicy struct {
+	// MaxRetries defaults to --retry-max-retries, 0 disables retries
+	MaxRetries *int32 `json:"maxRetries,omitempty"`
+	// InitialBackoff defaults to --retry-initial-backoff
+	InitialBackoff *metav1.Duration `json:"initialBackoff,omitempty"`
+	// MaxBackoff defaults to --retry-max-backoff. Retry-After longer than it is
+	// not waited for.
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
+}
+
+// SAEAPIServerRateLimit limits the requests proxied to SAE by token buckets
+type SAEAPIServerRateLimit struct {
+	// QPS defaults to --rate-limit-qps, 0 disables the limit
+	QPS *int32 `json:"qps,omitempty"`
+	// Burst defaults to --rate-limit-burst
+	Burst *int32 `json:"burst,omitempty"`
+	// PerUser limits each caller by a token bucket of its own as well, so that
+	// one caller cannot starve the others. Defaults to --rate-limit-per-user.
+	PerUser *bool `json:"perUser,omitempty"`
+	// QueueTimeout is how long over-limit requests wait for a token, those that
+	// would wait longer are rejected with 429 and Retry-After. Defaults to
+	// --rate-limit-queue-timeout, 0 rejects them at once.
+	QueueTimeout *metav1.Duration `json:"queueTimeout,omitempty"`
+}
+
+// SAEAPIServerCredential is a union, exactly one member must be set
+type SAEAPIServerCredential struct {
+	// Inline holds the AK/SK in the SAEAPIServer
+	Inline *SAEAPIServerInlineCredential `json:"inline,omitempty"`
+	// SecretRef loads the AK/SK from a secret
+	SecretRef *SAEAPIServerSecretReference `json:"secretRef,omitempty"`
+	// STS assumes a RAM role with the AK/SK
+	STS *SAEAPIServerSTSCredential `json:"sts,omitempty"`
+	// OIDC assumes a RAM role with the OIDC token of the proxy
+	OIDC *SAEAPIServerOIDCCredential `json:"oidc,omitempty"`
+	// CredentialRef uses the AK/SK of a SAECredential shared by SAEAPIServers
+	CredentialRef *SAECredentialReference `json:"credentialRef,omitempty"`
+}
+
+// SAEAPIServerInlineCredential holds the AK/SK directly
+type SAEAPIServerInlineCredential struct {
+	// AccessKeyId of the aliyun account
+	AccessKeyId string `json:"accessKeyId"`
+	// AccessKeySecret of the aliyun account
+	AccessKeySecret string `json:"accessKeySecret"`
+}
+
+// SAEAPIServerSecretReference refers to a secret holding the AK/SK
+type SAEAPIServerSecretReference struct {
+	// Namespace of the secret, which must be the storage namespace if set, as
+	// the proxy only reads the secrets there
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the secret
+	Name string `json:"name"`
+	// AccessKeyIdKey is the key of accessKeyId in the secret, defaults to accessKeyId
+	AccessKeyIdKey string `json:"accessKeyIdKey,omitempty"`
+	// AccessKeySecretKey is the key of accessKeySecret in the secret, defaults to accessKeySecret
+	AccessKeySecretKey string `json:"accessKeySecretKey,omitempty"`
+}
+
+// SAEAPIServerSTSCredential assumes the RAM role with the given AK/SK
+type SAEAPIServerSTSCredential struct {
+	// AccessKeyId of the RAM user allowed to assume the role
+	AccessKeyId string `json:"accessKeyId"`
+	// AccessKeySecret of the RAM user allowed to assume the role
+	AccessKeySecret string `json:"accessKeySecret"`
+	// RoleArn of the RAM role to assume
+	RoleArn string `json:"roleArn"`
+	// RoleSessionName defaults to sae-apiserver-proxy
+	RoleSessionName string `json:"roleSessionName,omitempty"`
+	// Policy further restricts the permissions of the assumed role
+	Policy string `json:"policy,omitempty"`
+}
+
+// SAECredentialReference refers to a SAECredential
+type SAECredentialReference struct {
+	// Name of the SAECredential
+	Name string `json:"name"`
+}
+
+// SAEAPIServerOIDCCredential assumes the RAM role with the OIDC token mounted
+// into the proxy, such as the one issued by ACK RRSA
+type SAEAPIServerOIDCCredential struct {
+	// RoleArn of the RAM role to assume
+	RoleArn string `json:"roleArn"`
+	// OIDCProviderArn of the OIDC identity provider registered in RAM
+	OIDCProviderArn string `json:"oidcProviderArn"`
+	// OIDCTokenFile defaults to the path in ALIBABA_CLOUD_OIDC_TOKEN_FILE
+	OIDCTokenFile string `json:"oidcTokenFile,omitempty"`
+	// RoleSessionName defaults to sae-apiserver-proxy
+	RoleSessionName string `json:"roleSessionName,omitempty"`
+	// STSEndpoint defaults to sts.aliyuncs.com, and must be allowed by
+	// --allowed-endpoints, as the OIDC token of the proxy is sent to it
+	STSEndpoint string `json:"stsEndpoint,omitempty"`
+}
+
+// SAEAPIServerEndpoint locates the SAE OpenAPI
+type SAEAPIServerEndpoint struct {
+	// Region defaults to cn-hangzhou
+	Region string `json:"region,omitempty"`
+	// OpenAPIEndpoint overrides the endpoint resolved from the region. It must be
+	// allowed by --allowed-endpoints, as the signed requests are sent to it.
+	OpenAPIEndpoint string `json:"openAPIEndpoint,omitempty"`
+	// Regions are the additional regions sharing the credential, which are
+	// served under proxy/regions/<region>/
+	// +listType=set
+	Regions []string `json:"regions,omitempty"`
+}
+
+// SAEAPIServerStatus is derived from the storage, it is read-only
+type SAEAPIServerStatus struct {
+	// ProxyEndpoint is the endpoint registered to cluster-gateway
+	ProxyEndpoint string `json:"proxyEndpoint,omitempty"`
+	// CredentialType is the credential type used by cluster-gateway to access the proxy
+	CredentialType string `json:"credentialType,omitempty"`
+	// RegionProxyEndpoints are the proxy endpoints of the additional regions
+	RegionProxyEndpoints map[string]string `json:"regionProxyEndpoints,omitempty"`
+}
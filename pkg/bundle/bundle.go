@@ -0,0 +1,169 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"k8s.io/client-go/util/keyutil"
+
+	"github.com/kubevela-contrib/sae-apiserver-proxy/pkg/apis/sae-apiserver/v1alpha1"
+)
+
+// Version is the version of the bundle format
+const Version = "sae.alibaba-cloud.oam.dev/bundle/v1"
+
+// Bundle is a portable export of the SAEAPIServers and SAECredentials of a hub.
+// The credentials are encrypted with AES-GCM by a data key, which is encrypted
+// to the RSA public key supplied at export.
+type Bundle struct {
+	Version string `json:"version"`
+	// EncryptedKey is the data key encrypted by RSA-OAEP with SHA-256
+	EncryptedKey []byte `json:"encryptedKey"`
+	// Credentials are the SAECredentials, imported before the SAEAPIServers
+	Credentials []Item `json:"credentials,omitempty"`
+	// APIServers are the SAEAPIServers
+	APIServers []Item `json:"apiservers,omitempty"`
+}
+
+// Item is an object of the bundle, of which the credential is taken out and encrypted
+type Item struct {
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Spec is the spec without the credential
+	Spec json.RawMessage `json:"spec,omitempty"`
+	// EncryptedCredential is the nonce followed by the sealed credential
+	EncryptedCredential []byte `json:"encryptedCredential"`
+}
+
+// sealer encrypts and decrypts the credentials of the bundle with the data key
+type sealer struct {
+	aead cipher.AEAD
+}
+
+func newSealer(key []byte) (*sealer, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead}, nil
+}
+
+// seal encrypts the credential, bound to the kind and the name of the item
+func (in *sealer) seal(kind, name string, credential interface{}) ([]byte, error) {
+	plaintext, err := json.Marshal(credential)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, in.aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return in.aead.Seal(nonce, nonce, plaintext, []byte(kind+"/"+name)), nil
+}
+
+func (in *sealer) open(kind, name string, sealed []byte, credential interface{}) error {
+	if len(sealed) < in.aead.NonceSize() {
+		return fmt.Errorf("invalid credential of %s %s", kind, name)
+	}
+	nonce, ciphertext := sealed[:in.aead.NonceSize()], sealed[in.aead.NonceSize():]
+	plaintext, err := in.aead.Open(nil, nonce, ciphertext, []byte(kind+"/"+name))
+	if err != nil {
+		return fmt.Errorf("cannot decrypt credential of %s %s: %w", kind, name, err)
+	}
+	return json.Unmarshal(plaintext, credential)
+}
+
+func parsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	keys, err := keyutil.ParsePublicKeysPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keys[0].(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("only RSA public keys are supported")
+	}
+	return key, nil
+}
+
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	key, err := keyutil.ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("only RSA private keys are supported")
+	}
+	return rsaKey, nil
+}
+
+// newBundle creates an empty bundle with a new data key encrypted to the public key
+func newBundle(publicKey *rsa.PublicKey) (*Bundle, *sealer, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, nil, err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, key, []byte(Version))
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot encrypt data key: %w", err)
+	}
+	s, err := newSealer(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Bundle{Version: Version, EncryptedKey: encryptedKey}, s, nil
+}
+
+// sealer decrypts the data key of the bundle with the private key
+func (in *Bundle) sealer(privateKey *rsa.PrivateKey) (*sealer, error) {
+	if in.Version != Version {
+		return nil, fmt.Errorf("unsupported bundle version %q", in.Version)
+	}
+	key, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, in.EncryptedKey, []byte(Version))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt data key: %w", err)
+	}
+	return newSealer(key)
+}
+
+// userMetadata drops the internal labels and annotations, which are regenerated on import
+func userMetadata(metadata map[string]string) map[string]string {
+	var filtered map[string]string
+	for k, v := range metadata {
+		if v1alpha1.IsInternalMetadataKey(k) {
+			continue
+		}
+		if filtered == nil {
+			filtered = map[string]string{}
+		}
+		filtered[k] = v
+	}
+	return filtered
+}
@@ -0,0 +1,335 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubevela-contrib/sae-apiserver-proxy/pkg/apis/sae-apiserver/v1alpha1"
+)
+
+const testStorageNamespace = "vela-system"
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestCredential(name, ak string) *v1alpha1.SAECredential {
+	return &v1alpha1.SAECredential{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"team": "sae"}},
+		Spec:       v1alpha1.SAECredentialSpec{AccessKeyId: ak, AccessKeySecret: ak + "-secret"},
+	}
+}
+
+func newTestAPIServer(name string, spec v1alpha1.SAEAPIServerSpec) *v1alpha1.SAEAPIServer {
+	return &v1alpha1.SAEAPIServer{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{"env": "prod", v1alpha1.LabelSAEAPIServerRegion: spec.Region},
+			Annotations: map[string]string{v1alpha1.AnnotationClusterAlias: name},
+		},
+		Spec: spec,
+	}
+}
+
+// exportTestBundle exports the objects and round-trips the bundle through json
+func exportTestBundle(t *testing.T, key *rsa.PrivateKey, objs ...client.Object) *Bundle {
+	bundle, err := Export(context.Background(), fake.NewClientBuilder().WithObjects(objs...).Build(), &key.PublicKey, testStorageNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("shared-ak")) || bytes.Contains(data, []byte("inline-ak")) {
+		t.Fatalf("expected the credentials to be encrypted, got %s", data)
+	}
+	bundle = &Bundle{}
+	if err = json.Unmarshal(data, bundle); err != nil {
+		t.Fatal(err)
+	}
+	return bundle
+}
+
+func TestExportImport(t *testing.T) {
+	key := newTestKey(t)
+	shared := newTestCredential("shared", "shared-ak")
+	inline := newTestAPIServer("inline", v1alpha1.SAEAPIServerSpec{
+		SAEAPIServerCredential: v1alpha1.SAEAPIServerCredential{AccessKeyId: "inline-ak", AccessKeySecret: "inline-sk"},
+		Region:                 "cn-beijing",
+		Regions:                []string{"cn-shanghai"},
+		Proxy:                  &v1alpha1.SAEAPIServerProxyConfig{CompressRequests: true},
+	})
+	referred := newTestAPIServer("referred", v1alpha1.SAEAPIServerSpec{
+		SAEAPIServerCredential: v1alpha1.SAEAPIServerCredential{CredentialRef: &v1alpha1.SAECredentialReference{Name: "shared"}},
+		Region:                 "cn-hangzhou",
+	})
+	bundle := exportTestBundle(t, key, shared, inline, referred)
+	if len(bundle.Credentials) != 1 || len(bundle.APIServers) != 2 {
+		t.Fatalf("unexpected bundle %+v", bundle)
+	}
+	for _, item := range bundle.APIServers {
+		if _, found := item.Labels[v1alpha1.LabelSAEAPIServerRegion]; found {
+			t.Fatalf("expected the internal labels to be dropped, got %v", item.Labels)
+		}
+	}
+
+	ctx := context.Background()
+	cli := fake.NewClientBuilder().Build()
+	results, err := Import(ctx, cli, bundle, key, ConflictPolicySkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Result{
+		{Kind: "SAECredential", Name: "shared", Action: ActionCreated},
+		{Kind: "SAEAPIServer", Name: "inline", Action: ActionCreated},
+		{Kind: "SAEAPIServer", Name: "referred", Action: ActionCreated},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, results)
+	}
+	credential := &v1alpha1.SAECredential{}
+	if err = cli.Get(ctx, types.NamespacedName{Name: "shared"}, credential); err != nil {
+		t.Fatal(err)
+	}
+	if credential.Spec != shared.Spec || credential.Labels["team"] != "sae" {
+		t.Fatalf("expected %+v, got %+v", shared, credential)
+	}
+	for _, apiserver := range []*v1alpha1.SAEAPIServer{inline, referred} {
+		imported := &v1alpha1.SAEAPIServer{}
+		if err = cli.Get(ctx, types.NamespacedName{Name: apiserver.Name}, imported); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(imported.Spec, apiserver.Spec) {
+			t.Fatalf("expected spec %+v, got %+v", apiserver.Spec, imported.Spec)
+		}
+		if imported.Labels["env"] != "prod" || imported.Annotations[v1alpha1.AnnotationClusterAlias] != apiserver.Name {
+			t.Fatalf("expected the user metadata, got %v %v", imported.Labels, imported.Annotations)
+		}
+	}
+}
+
+func TestExportCredentials(t *testing.T) {
+	key := newTestKey(t)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testStorageNamespace, Name: "aksk"},
+		Data:       map[string][]byte{"id": []byte("secret-ak"), "key": []byte("secret-sk")},
+	}
+	apiserver := newTestAPIServer("sae", v1alpha1.SAEAPIServerSpec{
+		SAEAPIServerCredential: v1alpha1.SAEAPIServerCredential{SecretRef: &v1alpha1.SAEAPIServerSecretReference{
+			Name: "aksk", AccessKeyIdKey: "id", AccessKeySecretKey: "key"}},
+		Region: "cn-hangzhou",
+	})
+	bundle := exportTestBundle(t, key, secret, apiserver)
+	ctx := context.Background()
+	cli := fake.NewClientBuilder().Build()
+	if _, err := Import(ctx, cli, bundle, key, ConflictPolicySkip); err != nil {
+		t.Fatal(err)
+	}
+	// the secret does not exist on the target hub, the AK/SK is imported inline
+	imported := &v1alpha1.SAEAPIServer{}
+	if err := cli.Get(ctx, types.NamespacedName{Name: "sae"}, imported); err != nil {
+		t.Fatal(err)
+	}
+	expected := v1alpha1.SAEAPIServerCredential{AccessKeyId: "secret-ak", AccessKeySecret: "secret-sk"}
+	if !reflect.DeepEqual(imported.Spec.SAEAPIServerCredential, expected) {
+		t.Fatalf("expected the inline credential %+v, got %+v", expected, imported.Spec.SAEAPIServerCredential)
+	}
+
+	cases := map[string]struct {
+		credential v1alpha1.SAEAPIServerCredential
+		mappings   []v1alpha1.SAEAPIServerIdentityMapping
+		// message is part of the expected error
+		message string
+	}{
+		"oidc": {credential: v1alpha1.SAEAPIServerCredential{OIDC: &v1alpha1.SAEAPIServerOIDCCredential{
+			RoleArn: "acs:ram::1:role/sae", OIDCProviderArn: "acs:ram::1:oidc-provider/ack"}}, message: "OIDC"},
+		"missing secret":  {credential: v1alpha1.SAEAPIServerCredential{SecretRef: &v1alpha1.SAEAPIServerSecretReference{Name: "missing"}}, message: "vela-system/missing"},
+		"missing key":     {credential: v1alpha1.SAEAPIServerCredential{SecretRef: &v1alpha1.SAEAPIServerSecretReference{Name: "aksk"}}, message: "accessKey not found"},
+		"missing ref":     {credential: v1alpha1.SAEAPIServerCredential{CredentialRef: &v1alpha1.SAECredentialReference{Name: "missing"}}, message: "SAECredential missing"},
+		"missing mapping": {credential: expected, mappings: []v1alpha1.SAEAPIServerIdentityMapping{{Users: []string{"alice"}, CredentialRef: &v1alpha1.SAECredentialReference{Name: "missing"}}}, message: "SAECredential missing"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			apiserver := newTestAPIServer("sae", v1alpha1.SAEAPIServerSpec{SAEAPIServerCredential: c.credential, Region: "cn-hangzhou", IdentityMappings: c.mappings})
+			cli := fake.NewClientBuilder().WithObjects(secret, apiserver).Build()
+			_, err := Export(ctx, cli, &key.PublicKey, testStorageNamespace)
+			if err == nil || !strings.Contains(err.Error(), "SAEAPIServer sae") || !strings.Contains(err.Error(), c.message) {
+				t.Fatalf("expected the export to be refused for %s, got %v", c.message, err)
+			}
+		})
+	}
+}
+
+func TestImportConflictPolicies(t *testing.T) {
+	key := newTestKey(t)
+	mapped := newTestCredential("mapped", "mapped-ak")
+	shared := newTestCredential("shared", "shared-ak")
+	spec := v1alpha1.SAEAPIServerSpec{
+		SAEAPIServerCredential: v1alpha1.SAEAPIServerCredential{CredentialRef: &v1alpha1.SAECredentialReference{Name: "shared"}},
+		Region:                 "cn-hangzhou",
+		IdentityMappings: []v1alpha1.SAEAPIServerIdentityMapping{
+			{Users: []string{"alice"}, CredentialRef: &v1alpha1.SAECredentialReference{Name: "mapped"}},
+			{Groups: []string{"dev"}, RoleArn: "acs:ram::1:role/dev"},
+		},
+	}
+	bundle := exportTestBundle(t, key, mapped, shared, newTestAPIServer("sae", spec))
+	// the existing objects of the target hub with the same names
+	existing := func() []client.Object {
+		return []client.Object{
+			newTestCredential("shared", "existing-ak"),
+			newTestCredential("shared-1", "existing-ak"),
+			newTestAPIServer("sae", v1alpha1.SAEAPIServerSpec{
+				SAEAPIServerCredential: v1alpha1.SAEAPIServerCredential{AccessKeyId: "existing-ak", AccessKeySecret: "existing-sk"},
+				Region:                 "cn-beijing",
+			}),
+		}
+	}
+	ctx := context.Background()
+
+	t.Run("skip", func(t *testing.T) {
+		cli := fake.NewClientBuilder().WithObjects(existing()...).Build()
+		results, err := Import(ctx, cli, bundle, key, ConflictPolicySkip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Result{
+			{Kind: "SAECredential", Name: "mapped", Action: ActionCreated},
+			{Kind: "SAECredential", Name: "shared", Action: ActionSkipped},
+			{Kind: "SAEAPIServer", Name: "sae", Action: ActionSkipped},
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Fatalf("expected %+v, got %+v", expected, results)
+		}
+		credential := &v1alpha1.SAECredential{}
+		if err = cli.Get(ctx, types.NamespacedName{Name: "shared"}, credential); err != nil || credential.Spec.AccessKeyId != "existing-ak" {
+			t.Fatalf("expected the existing credential to be kept, got %+v %v", credential.Spec, err)
+		}
+	})
+
+	t.Run("overwrite", func(t *testing.T) {
+		cli := fake.NewClientBuilder().WithObjects(existing()...).Build()
+		results, err := Import(ctx, cli, bundle, key, ConflictPolicyOverwrite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results[1].Action != ActionOverwritten || results[2].Action != ActionOverwritten {
+			t.Fatalf("expected the existing objects to be overwritten, got %+v", results)
+		}
+		apiserver := &v1alpha1.SAEAPIServer{}
+		if err = cli.Get(ctx, types.NamespacedName{Name: "sae"}, apiserver); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(apiserver.Spec, spec) {
+			t.Fatalf("expected spec %+v, got %+v", spec, apiserver.Spec)
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		cli := fake.NewClientBuilder().WithObjects(existing()...).Build()
+		results, err := Import(ctx, cli, bundle, key, ConflictPolicyRename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Result{
+			{Kind: "SAECredential", Name: "mapped", Action: ActionCreated},
+			{Kind: "SAECredential", Name: "shared-2", Action: ActionRenamed},
+			{Kind: "SAEAPIServer", Name: "sae-1", Action: ActionRenamed},
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Fatalf("expected %+v, got %+v", expected, results)
+		}
+		credential := &v1alpha1.SAECredential{}
+		if err = cli.Get(ctx, types.NamespacedName{Name: "shared-2"}, credential); err != nil || credential.Spec != shared.Spec {
+			t.Fatalf("expected the renamed credential, got %+v %v", credential.Spec, err)
+		}
+		apiserver := &v1alpha1.SAEAPIServer{}
+		if err = cli.Get(ctx, types.NamespacedName{Name: "sae-1"}, apiserver); err != nil {
+			t.Fatal(err)
+		}
+		if ref := apiserver.Spec.CredentialRef; ref == nil || ref.Name != "shared-2" {
+			t.Fatalf("expected the credentialRef to follow the renamed credential, got %+v", ref)
+		}
+		if ref := apiserver.Spec.IdentityMappings[0].CredentialRef; ref == nil || ref.Name != "mapped" {
+			t.Fatalf("expected the credentialRef of the mapping to be kept, got %+v", ref)
+		}
+		existingAPIServer := &v1alpha1.SAEAPIServer{}
+		if err = cli.Get(ctx, types.NamespacedName{Name: "sae"}, existingAPIServer); err != nil || existingAPIServer.Spec.AccessKeyId != "existing-ak" {
+			t.Fatalf("expected the existing SAEAPIServer to be kept, got %+v %v", existingAPIServer.Spec, err)
+		}
+	})
+
+	t.Run("rename mapping", func(t *testing.T) {
+		objs := append(existing(), newTestCredential("mapped", "existing-ak"))
+		cli := fake.NewClientBuilder().WithObjects(objs...).Build()
+		if _, err := Import(ctx, cli, bundle, key, ConflictPolicyRename); err != nil {
+			t.Fatal(err)
+		}
+		apiserver := &v1alpha1.SAEAPIServer{}
+		if err := cli.Get(ctx, types.NamespacedName{Name: "sae-1"}, apiserver); err != nil {
+			t.Fatal(err)
+		}
+		if ref := apiserver.Spec.IdentityMappings[0].CredentialRef; ref == nil || ref.Name != "mapped-1" {
+			t.Fatalf("expected the credentialRef of the mapping to follow the renamed credential, got %+v", ref)
+		}
+		if ref := apiserver.Spec.CredentialRef; ref == nil || ref.Name != "shared-2" {
+			t.Fatalf("expected the credentialRef to follow the renamed credential, got %+v", ref)
+		}
+	})
+}
+
+func TestImportRejects(t *testing.T) {
+	key := newTestKey(t)
+	bundle := exportTestBundle(t, key, newTestCredential("shared", "shared-ak"))
+	ctx := context.Background()
+	cli := fake.NewClientBuilder().Build()
+	if _, err := Import(ctx, cli, bundle, key, ConflictPolicy("merge")); err == nil {
+		t.Fatal("expected the unknown conflict policy to be rejected")
+	}
+	if _, err := Import(ctx, cli, bundle, newTestKey(t), ConflictPolicySkip); err == nil {
+		t.Fatal("expected the bundle not to be decrypted by another key")
+	}
+	tampered := *bundle
+	tampered.Credentials = []Item{bundle.Credentials[0]}
+	tampered.Credentials[0].Name = "other"
+	if _, err := Import(ctx, cli, &tampered, key, ConflictPolicySkip); err == nil {
+		t.Fatal("expected the credential sealed for another name to be rejected")
+	}
+	unsupported := *bundle
+	unsupported.Version = "v0"
+	if _, err := Import(ctx, cli, &unsupported, key, ConflictPolicySkip); err == nil {
+		t.Fatal("expected the unsupported version to be rejected")
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/kubevela/pkg/util/singleton"
+	"github.com/spf13/cobra"
+)
+
+// NewExportCommand creates the command exporting the SAEAPIServers of the hub
+// in the current kubeconfig
+func NewExportCommand() *cobra.Command {
+	var publicKeyFile, output, storageNamespace string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export all the SAEAPIServers and SAECredentials into a bundle.",
+		Long:  "Export all the SAEAPIServers and SAECredentials into a bundle, with the credentials encrypted to the RSA public key.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(publicKeyFile)
+			if err != nil {
+				return err
+			}
+			publicKey, err := parsePublicKey(data)
+			if err != nil {
+				return fmt.Errorf("invalid public key: %w", err)
+			}
+			bundle, err := Export(cmd.Context(), singleton.KubeClient.Get(), publicKey, storageNamespace)
+			if err != nil {
+				return err
+			}
+			bs, err := json.MarshalIndent(bundle, "", "  ")
+			if err != nil {
+				return err
+			}
+			if output == "" || output == "-" {
+				_, err = cmd.OutOrStdout().Write(append(bs, '\n'))
+				return err
+			}
+			return os.WriteFile(output, bs, 0o600)
+		},
+	}
+	cmd.Flags().StringVarP(&publicKeyFile, "public-key", "", "", "The PEM file of the RSA public key to encrypt the credentials to.")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "The file to write the bundle to, defaults to stdout.")
+	cmd.Flags().StringVarP(&storageNamespace, "storage-namespace", "", "vela-system", "The storage namespace of the proxy, where the secrets referred by secretRef are loaded from.")
+	_ = cmd.MarkFlagRequired("public-key")
+	return cmd
+}
+
+// NewImportCommand creates the command importing a bundle into the hub in the
+// current kubeconfig
+func NewImportCommand() *cobra.Command {
+	var privateKeyFile, file, policy string
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import the SAEAPIServers and SAECredentials from a bundle.",
+		Long:  "Import the SAEAPIServers and SAECredentials from a bundle, with the credentials decrypted by the RSA private key.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := os.ReadFile(privateKeyFile)
+			if err != nil {
+				return err
+			}
+			privateKey, err := parsePrivateKey(data)
+			if err != nil {
+				return fmt.Errorf("invalid private key: %w", err)
+			}
+			if data, err = os.ReadFile(file); err != nil {
+				return err
+			}
+			bundle := &Bundle{}
+			if err = json.Unmarshal(data, bundle); err != nil {
+				return fmt.Errorf("invalid bundle: %w", err)
+			}
+			results, err := Import(cmd.Context(), singleton.KubeClient.Get(), bundle, privateKey, ConflictPolicy(policy))
+			for _, result := range results {
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s %s %s\n", result.Kind, result.Name, result.Action)
+			}
+			return err
+		},
+	}
+	cmd.Flags().StringVarP(&privateKeyFile, "private-key", "", "", "The PEM file of the RSA private key to decrypt the credentials.")
+	cmd.Flags().StringVarP(&file, "file", "f", "", "The bundle file to import.")
+	cmd.Flags().StringVarP(&policy, "conflict-policy", "", string(ConflictPolicySkip), "What to do with existing objects, one of skip, overwrite and rename.")
+	_ = cmd.MarkFlagRequired("private-key")
+	_ = cmd.MarkFlagRequired("file")
+	return cmd
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela-contrib/sae-apiserver-proxy/pkg/apis/sae-apiserver/v1alpha1"
+)
+
+// Export exports all the SAEAPIServers and SAECredentials into a bundle, with
+// the credentials encrypted to the public key. The credentials must not depend
+// on this hub: the AK/SK of a secretRef is loaded from the secret in the storage
+// namespace and exported inline, the SAECredentials referred must be exported
+// along, and OIDC credentials are refused.
+func Export(ctx context.Context, cli client.Client, publicKey *rsa.PublicKey, storageNamespace string) (*Bundle, error) {
+	bundle, s, err := newBundle(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	credentials := &v1alpha1.SAECredentialList{}
+	if err = cli.List(ctx, credentials); err != nil {
+		return nil, err
+	}
+	exported := map[string]bool{}
+	for _, credential := range credentials.Items {
+		exported[credential.Name] = true
+		item := Item{Name: credential.Name, Labels: userMetadata(credential.Labels), Annotations: userMetadata(credential.Annotations)}
+		if item.EncryptedCredential, err = s.seal("SAECredential", credential.Name, credential.Spec); err != nil {
+			return nil, err
+		}
+		bundle.Credentials = append(bundle.Credentials, item)
+	}
+	apiservers := &v1alpha1.SAEAPIServerList{}
+	if err = cli.List(ctx, apiservers); err != nil {
+		return nil, err
+	}
+	for _, apiserver := range apiservers.Items {
+		credential, err := exportCredential(ctx, cli, &apiserver, storageNamespace, exported)
+		if err != nil {
+			return nil, fmt.Errorf("cannot export SAEAPIServer %s: %w", apiserver.Name, err)
+		}
+		item := Item{Name: apiserver.Name, Labels: userMetadata(apiserver.Labels), Annotations: userMetadata(apiserver.Annotations)}
+		if item.EncryptedCredential, err = s.seal("SAEAPIServer", apiserver.Name, credential); err != nil {
+			return nil, err
+		}
+		spec := apiserver.Spec.DeepCopy()
+		spec.SAEAPIServerCredential = v1alpha1.SAEAPIServerCredential{}
+		if item.Spec, err = json.Marshal(spec); err != nil {
+			return nil, err
+		}
+		bundle.APIServers = append(bundle.APIServers, item)
+	}
+	return bundle, nil
+}
+
+// exportCredential returns the credential of the SAEAPIServer usable on another
+// hub, with the AK/SK of the secretRef loaded inline
+func exportCredential(ctx context.Context, cli client.Client, apiserver *v1alpha1.SAEAPIServer, storageNamespace string, exported map[string]bool) (*v1alpha1.SAEAPIServerCredential, error) {
+	for _, ref := range apiserver.Spec.CredentialRefs() {
+		if !exported[ref.Name] {
+			return nil, fmt.Errorf("the referred SAECredential %s does not exist", ref.Name)
+		}
+	}
+	credential := apiserver.Spec.SAEAPIServerCredential.DeepCopy()
+	switch credential.GetType() {
+	case v1alpha1.CredentialTypeOIDC:
+		return nil, fmt.Errorf("the OIDC credential assumes the role with the token mounted into the proxy of this hub, " +
+			"which the role does not trust on another hub, change it to another credential before exporting")
+	case v1alpha1.CredentialTypeSecretRef:
+		ref := credential.SecretRef
+		namespace, idKey, secretKey := ref.Namespace, ref.AccessKeyIdKey, ref.AccessKeySecretKey
+		if namespace == "" {
+			namespace = storageNamespace
+		}
+		if idKey == "" {
+			idKey = v1alpha1.IdentAccessKeyId
+		}
+		if secretKey == "" {
+			secretKey = v1alpha1.IdentAccessKeySecret
+		}
+		secret := &corev1.Secret{}
+		if err := cli.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, secret); err != nil {
+			return nil, fmt.Errorf("cannot load the credential secret %s/%s: %w", namespace, ref.Name, err)
+		}
+		id, f1 := secret.Data[idKey]
+		key, f2 := secret.Data[secretKey]
+		if !f1 || !f2 {
+			return nil, fmt.Errorf("accessKey not found in the credential secret %s/%s", namespace, ref.Name)
+		}
+		return &v1alpha1.SAEAPIServerCredential{AccessKeyId: string(id), AccessKeySecret: string(key)}, nil
+	default:
+		return credential, nil
+	}
+}
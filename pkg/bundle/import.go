@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The KubeVela Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/kubevela-contrib/sae-apiserver-proxy/pkg/apis/sae-apiserver/v1alpha1"
+)
+
+// ConflictPolicy decides what to do when an imported object already exists
+type ConflictPolicy string
+
+const (
+	// ConflictPolicySkip keeps the existing object
+	ConflictPolicySkip ConflictPolicy = "skip"
+	// ConflictPolicyOverwrite replaces the existing object with the imported one
+	ConflictPolicyOverwrite ConflictPolicy = "overwrite"
+	// ConflictPolicyRename imports the object with a new name suffixed by -<n>
+	ConflictPolicyRename ConflictPolicy = "rename"
+)
+
+// Result is the outcome of importing an object
+type Result struct {
+	Kind   string
+	Name   string
+	Action string
+}
+
+const (
+	ActionCreated     = "created"
+	ActionSkipped     = "skipped"
+	ActionOverwritten = "overwritten"
+	ActionRenamed     = "renamed"
+)
+
+const maxRenameAttempts = 100
+
+// Import recreates the SAECredentials and SAEAPIServers in the bundle, with the
+// credentials decrypted by the private key. References to renamed SAECredentials
+// are updated accordingly.
+func Import(ctx context.Context, cli client.Client, bundle *Bundle, privateKey *rsa.PrivateKey, policy ConflictPolicy) ([]Result, error) {
+	switch policy {
+	case ConflictPolicySkip, ConflictPolicyOverwrite, ConflictPolicyRename:
+	default:
+		return nil, fmt.Errorf("unknown conflict policy %q", policy)
+	}
+	s, err := bundle.sealer(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	renamedCredentials := map[string]string{}
+	for _, item := range bundle.Credentials {
+		credential := &v1alpha1.SAECredential{}
+		credential.SetName(item.Name)
+		credential.SetLabels(item.Labels)
+		credential.SetAnnotations(item.Annotations)
+		if err = s.open("SAECredential", item.Name, item.EncryptedCredential, &credential.Spec); err != nil {
+			return results, err
+		}
+		result, err := importObject(ctx, cli, "SAECredential", credential, &v1alpha1.SAECredential{}, policy, func(existing client.Object) {
+			existing.(*v1alpha1.SAECredential).Spec = credential.Spec
+		})
+		if err != nil {
+			return results, err
+		}
+		if result.Name != item.Name {
+			renamedCredentials[item.Name] = result.Name
+		}
+		results = append(results, result)
+	}
+	for _, item := range bundle.APIServers {
+		apiserver := &v1alpha1.SAEAPIServer{}
+		apiserver.SetName(item.Name)
+		apiserver.SetLabels(item.Labels)
+		apiserver.SetAnnotations(item.Annotations)
+		if len(item.Spec) > 0 {
+			if err = json.Unmarshal(item.Spec, &apiserver.Spec); err != nil {
+				return results, fmt.Errorf("invalid spec of SAEAPIServer %s: %w", item.Name, err)
+			}
+		}
+		if err = s.open("SAEAPIServer", item.Name, item.EncryptedCredential, &apiserver.Spec.SAEAPIServerCredential); err != nil {
+			return results, err
+		}
+		for _, ref := range apiserver.Spec.CredentialRefs() {
+			if renamed := renamedCredentials[ref.Name]; renamed != "" {
+				ref.Name = renamed
+			}
+		}
+		result, err := importObject(ctx, cli, "SAEAPIServer", apiserver, &v1alpha1.SAEAPIServer{}, policy, func(existing client.Object) {
+			existing.(*v1alpha1.SAEAPIServer).Spec = apiserver.Spec
+		})
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// importObject creates the object, or resolves the conflict with the existing
+// one by the policy. The overwrite func copies the imported spec to the existing object.
+func importObject(ctx context.Context, cli client.Client, kind string, obj client.Object, existing client.Object, policy ConflictPolicy, overwrite func(existing client.Object)) (Result, error) {
+	name := obj.GetName()
+	err := cli.Create(ctx, obj)
+	if err == nil {
+		return Result{Kind: kind, Name: name, Action: ActionCreated}, nil
+	}
+	if !apierrors.IsAlreadyExists(err) {
+		return Result{}, fmt.Errorf("cannot create %s %s: %w", kind, name, err)
+	}
+	switch policy {
+	case ConflictPolicyOverwrite:
+		if err = cli.Get(ctx, types.NamespacedName{Name: name}, existing); err != nil {
+			return Result{}, err
+		}
+		overwrite(existing)
+		existing.SetLabels(obj.GetLabels())
+		existing.SetAnnotations(obj.GetAnnotations())
+		if err = cli.Update(ctx, existing); err != nil {
+			return Result{}, fmt.Errorf("cannot overwrite %s %s: %w", kind, name, err)
+		}
+		return Result{Kind: kind, Name: name, Action: ActionOverwritten}, nil
+	case ConflictPolicyRename:
+		for i := 1; i <= maxRenameAttempts; i++ {
+			obj.SetName(fmt.Sprintf("%s-%d", name, i))
+			if err = cli.Create(ctx, obj); err == nil {
+				return Result{Kind: kind, Name: obj.GetName(), Action: ActionRenamed}, nil
+			}
+			if !apierrors.IsAlreadyExists(err) {
+				return Result{}, fmt.Errorf("cannot create %s %s: %w", kind, obj.GetName(), err)
+			}
+		}
+		return Result{}, fmt.Errorf("cannot find a free name for %s %s", kind, name)
+	default:
+		return Result{Kind: kind, Name: name, Action: ActionSkipped}, nil
+	}
+}